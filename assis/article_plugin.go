@@ -4,25 +4,20 @@ import (
 	"fmt"
 	"github.com/gammazero/workerpool"
 	"github.com/gomarkdown/markdown"
-	"github.com/gosimple/slug"
 	"go.uber.org/zap"
 	"html/template"
-	"io/ioutil"
-	"net/mail"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type Tags []string
 
+// Article is the view of a markdown page templates and collections work
+// with. Every Page field, like Title, Content or Params, is promoted.
 type Article struct {
-	ID        string
-	Permalink string
-	Title     string
-	Date      string
-	Content   template.HTML
+	*Page
 	Preview   template.HTML
 	Template  string
 	Pin       bool
@@ -31,70 +26,33 @@ type Article struct {
 	Authors   []string
 }
 
-func newArticle(filename, relative string) (Article, error) {
-	b, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return Article{}, err
-	}
-
-	r := strings.NewReader(string(b))
-	msg, err := mail.ReadMessage(r)
-	if err != nil {
-		return Article{}, err
-	}
-
-	body, err := ioutil.ReadAll(msg.Body)
-	if err != nil {
-		return Article{}, err
-	}
-
-	id := slug.Make(msg.Header.Get("title"))
+const previewSize = 500
 
-	pinned := false
-	if msg.Header.Get("pin") == "true" {
-		pinned = true
-	}
-
-	active := true
-	if msg.Header.Get("active") == "false" {
-		active = false
-	}
-
-	var tags []string
-	if msg.Header.Get("tags") != "" {
-		tags = strings.Split(msg.Header.Get("tags"), ",")
-		for i := 0; i < len(tags); i++ {
-			tags[i] = strings.TrimSpace(tags[i])
-		}
-	}
-
-	var authors []string
-	if msg.Header.Get("authors") != "" {
-		authors = strings.Split(msg.Header.Get("authors"), ",")
-		for i := 0; i < len(authors); i++ {
-			authors[i] = strings.TrimSpace(authors[i])
+func newArticle(page *Page) Article {
+	preview := page.RawContent
+	if len(preview) > previewSize {
+		preview = preview[:previewSize]
+		for !utf8.ValidString(preview) {
+			preview = preview[:len(preview)-1]
 		}
 	}
 
 	return Article{
-		ID:        id,
-		Permalink: fmt.Sprintf("%s/%s.html", relative, id),
-		Title:     msg.Header.Get("title"),
-		Date:      msg.Header.Get("date"),
-		Content:   template.HTML(markdown.ToHTML(body, nil, nil)),
-		Preview:   template.HTML(markdown.ToHTML(body[:500], nil, nil)),
-		Template:  msg.Header.Get("template"),
-		Pin:       pinned,
-		Published: active,
-		Tags:      tags,
-		Authors:   authors,
-	}, nil
+		Page:      page,
+		Preview:   template.HTML(markdown.ToHTML([]byte(preview), nil, nil)),
+		Template:  page.Layout,
+		Pin:       boolParam(page.Params, "pin", false),
+		Published: boolParam(page.Params, "active", true),
+		Tags:      listParam(page.Params, "tags"),
+		Authors:   listParam(page.Params, "authors"),
+	}
 }
 
+// ArticlePlugin renders the markdown articles. Its collections read from the
+// snapshot of the build, so they are complete whenever a template calls them.
 type ArticlePlugin struct {
 	config    *Config
 	templates map[string]*template.Template
-	files     map[string][]Article
 	name      string
 	logger    *zap.Logger
 }
@@ -103,20 +61,39 @@ func NewArticlePlugin(config *Config, logger *zap.Logger) ArticlePlugin {
 	return ArticlePlugin{
 		config:    config,
 		templates: map[string]*template.Template{},
-		files:     map[string][]Article{},
 		name:      "markdown",
 		logger:    logger,
 	}
 }
 
+func (m ArticlePlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "articles", Version: builtinVersion}
+}
+
 func (m ArticlePlugin) OnRegisterCustomFunction() map[string]interface{} {
 	return map[string]interface{}{
-		"articleCollection": m.articleCollection,
-		"pinCollection":     m.pinCollection,
-		"generateSearch":    m.generateSearch,
-		"tags":              m.tags,
-		"limit":             m.limit,
-		"orderByDate":       m.orderByDate,
+		"generateSearch": m.generateSearch,
+		"tags":           m.tags,
+		"limit":          m.limit,
+		"orderByDate":    m.orderByDate,
+	}
+}
+
+func (m ArticlePlugin) OnRegisterSnapshotFunction(snapshot *Snapshot) map[string]interface{} {
+	return map[string]interface{}{
+		"articleCollection": func(path string) []Article {
+			return snapshot.collection(path, false, false)
+		},
+		"pinCollection": func(path string) []Article {
+			return snapshot.collection(path, true, false)
+		},
+		"articleCollectionRecursive": func(path string) []Article {
+			return snapshot.collection(path, false, true)
+		},
+		"pinCollectionRecursive": func(path string) []Article {
+			return snapshot.collection(path, true, true)
+		},
+		"allArticles": snapshot.allArticles,
 	}
 }
 
@@ -142,29 +119,6 @@ func (m ArticlePlugin) tags(articles []Article) Tags {
 	return tags
 }
 
-func (m ArticlePlugin) getCollection(path string, pin bool) []Article {
-	for entry, collection := range m.files {
-		if entry == m.config.Content+path {
-			var out []Article
-			for _, f := range collection {
-				if f.Pin == pin && f.Published {
-					out = append(out, f)
-				}
-			}
-			return out
-		}
-	}
-	return []Article{}
-}
-
-func (m ArticlePlugin) articleCollection(path string) []Article {
-	return m.getCollection(path, false)
-}
-
-func (m ArticlePlugin) pinCollection(path string) []Article {
-	return m.getCollection(path, true)
-}
-
 func (m ArticlePlugin) limit(size int, list []Article) []Article {
 	if len(list) == 0 {
 		return []Article{}
@@ -189,16 +143,15 @@ func (m ArticlePlugin) orderByDate(dir string, list []Article) []Article {
 	return tmpList
 }
 
-func (m ArticlePlugin) OnRender(t AssisTemplate, siteFiles SiteFiles, templates Templates) error {
+func (m ArticlePlugin) OnRender(t AssisTemplate, site *Site, siteFiles SiteFiles, templates Templates) error {
 	m.logger.Info("Start Article rendering")
+	var errs errorCollector
 	wp := workerpool.New(2)
 	maxJobs := 0
-	for _, container := range siteFiles {
-		container := container
+	for _, section := range site.Sections {
+		section := section
 		wp.Submit(func() {
-			if err := m.processContainer(container, t, templates); err != nil {
-				m.logger.Error(err.Error())
-			}
+			errs.add(m.processSection(section, t, templates))
 		})
 		maxJobs += 1
 		if maxJobs == 4 {
@@ -209,51 +162,40 @@ func (m ArticlePlugin) OnRender(t AssisTemplate, siteFiles SiteFiles, templates
 	}
 	wp.StopWait()
 	m.logger.Info("Finished Article rendering")
-	return nil
+	return errs.err()
 }
 
-func (m ArticlePlugin) processContainer(container *FileContainer, t AssisTemplate, templates Templates) error {
-	markdownFiles := container.FilterExt([]string{MD})
-	for _, file := range markdownFiles {
-		m.logger.Info("Read Article: " + container.FullFilename(file))
-		rel, _ := filepath.Rel(m.config.Content, container.entry)
-		parsed, err := newArticle(container.FullFilename(file), rel)
-		if err != nil {
-			return err
+// processSection renders the articles of a section, carrying on past the
+// ones that fail.
+func (m ArticlePlugin) processSection(section *Section, t AssisTemplate, templates Templates) error {
+	var errs RenderErrors
+	for _, page := range section.Pages {
+		if page.Kind != KindArticle {
+			continue
 		}
+		parsed := t.Snapshot().article(page)
 
-		m.files[container.entry] = append(m.files[container.entry], parsed)
+		for _, output := range page.Outputs() {
+			article := parsed
+			article.Page = output
 
-		output := strings.Replace(container.OutputFilename(file), string(file), parsed.ID+".html", 1)
-
-		err = func() error {
-			target, err := CreateTargetFile(output)
-			defer target.Close()
-			if err != nil {
-				return err
+			templateFile, err := templates.Lookup(output)
+			if err == nil && templateFile == "" {
+				err = fmt.Errorf("%s: no template, add a layout header or a %s template",
+					output.Source, formatName(SingleTemplate, output.Format))
 			}
-
-			templateFile, err := filepath.Abs(fmt.Sprintf("%s\\%s", m.config.Template.Path, parsed.Template))
-			if err != nil {
-				return err
+			if err == nil {
+				err = t.RenderLayout(templates, templateFile, output.Output, article)
 			}
-
-			targetTemplate, err := t.GetTemplate().ParseFiles(append(templates.baseOrdered, templateFile)...)
 			if err != nil {
-				return err
+				errs = append(errs, err)
+				continue
 			}
-
-			if err = targetTemplate.ExecuteTemplate(target, "layout", parsed); err != nil {
-				return err
-			}
-
-			m.logger.Info("Rendered markdown to: " + target.Name())
-			return nil
-		}()
-
-		if err != nil {
-			return err
+			m.logger.Info("Rendered markdown to: " + output.Output)
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
@@ -1,9 +1,11 @@
 package assis
 
 import (
+	"context"
 	"fmt"
 	"go.uber.org/zap"
 	"io/fs"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -13,55 +15,186 @@ import (
 const HTML = ".html"
 const MD = ".md"
 
+// Templates looked up for a page without a layout, and the folder holding the
+// ones used by every section.
+const (
+	SingleTemplate   = "single.html"
+	ListTemplate     = "list.html"
+	DefaultTemplates = "_default"
+)
+
 type PluginRender interface {
-	OnRender(AssisTemplate, SiteFiles, Templates) error
+	OnRender(AssisTemplate, *Site, SiteFiles, Templates) error
 }
 
 type PluginGeneratedFiles interface {
-	AfterGeneratedFiles([]string) error
+	AfterGeneratedFiles(*Site, []string) error
 }
 
 type PluginLoadFiles interface {
-	AfterLoadFiles(SiteFiles) error
+	AfterLoadFiles(*Site, SiteFiles) error
+}
+
+// PluginBuildSite lets a plugin add its own pages to the site graph before
+// sections and taxonomies are indexed.
+type PluginBuildSite interface {
+	OnBuildSite(*Site) error
 }
 
 type PluginCustomFunction interface {
 	OnRegisterCustomFunction() map[string]interface{}
 }
 
+// Templates are the template files of a build, read from the site and its
+// themes. Files are known by their path relative to their template folder, so
+// a site file overrides the theme file of the same path.
 type Templates struct {
 	cfg          *Config
 	baseTemplate string
 	partials     []string
-	files        []string
+	partialIndex map[string]int
+	files        map[string]string
 	baseOrdered  []string
+	cache        *TemplateCache
 }
 
 func NewTemplates(config *Config) Templates {
 	return Templates{
-		cfg:         config,
-		partials:    []string{},
-		files:       []string{},
-		baseOrdered: []string{},
+		cfg:          config,
+		partials:     []string{},
+		partialIndex: map[string]int{},
+		files:        map[string]string{},
+		baseOrdered:  []string{},
+		cache:        NewTemplateCache(),
 	}
 }
 
 func (t *Templates) orderBaseTemplate() {
 	t.baseOrdered = []string{t.baseTemplate}
-	t.baseOrdered = append(t.baseOrdered, t.partials...)
+	t.baseOrdered = append(t.baseOrdered, t.Partials(HTML)...)
+}
+
+// Partials returns the partials written in the format of ext, e.g. ".xml".
+func (t *Templates) Partials(ext string) []string {
+	var partials []string
+	for _, partial := range t.partials {
+		if filepath.Ext(partial) == ext {
+			partials = append(partials, partial)
+		}
+	}
+	return partials
+}
+
+// role returns the role a file plays in rendering the page of source: base,
+// partial, template or content.
+func (t *Templates) role(file, source string) string {
+	switch {
+	case file == source:
+		return "content"
+	case file == t.baseTemplate:
+		return "base"
+	}
+	for _, partial := range t.partials {
+		if partial == file {
+			return "partial"
+		}
+	}
+	return "template"
+}
+
+// addPartial adds a partial, replacing the one of a lower layer with the same
+// path while keeping its place, so partials of a theme are parsed first.
+func (t *Templates) addPartial(rel, file string) {
+	if i, ok := t.partialIndex[rel]; ok {
+		t.partials[i] = file
+		return
+	}
+	t.partialIndex[rel] = len(t.partials)
+	t.partials = append(t.partials, file)
 }
-func (t *Templates) GetTemplatesByDir(fileToRender string) []string {
-	fn := func(path string) int {
-		return len(strings.Split(filepath.ToSlash(filepath.Dir(path)), "/"))
+
+// Lookup returns the template file rendering a page. The layout of its front
+// matter wins; otherwise single.html is looked up in the template folder of
+// the page section, then of each section above it, then in _default. Section
+// and list pages look for list.html instead, ending with the list.html at the
+// root of the template folder. Each name is first looked up with the format of
+// the page in it, e.g. single.amp.html or single.json.json, then with the
+// extension of the format only, e.g. single.json. Alternative outputs whose
+// layout has no template for their format fall back to the lookup by section.
+// It returns "" when there is no template.
+func (t *Templates) Lookup(page *Page) (string, error) {
+	layoutNames, names := t.lookupNames(page)
+	for _, name := range layoutNames {
+		if file, ok := t.resolve(name); ok {
+			return file, nil
+		}
+	}
+	if len(layoutNames) > 0 && page.primary == nil {
+		return "", fmt.Errorf("%s: layout %s not found in the site or theme templates", page.Source, page.Layout)
+	}
+
+	for _, name := range names {
+		if file, ok := t.resolve(name); ok {
+			return file, nil
+		}
 	}
+	return "", nil
+}
 
-	var out []string
-	for _, tpl := range t.files {
-		if fn(fileToRender) == fn(tpl) {
-			out = append(out, tpl)
+// lookupNames returns the template names Lookup tries for a page, in order:
+// the ones of its layout, then the ones found by section.
+func (t *Templates) lookupNames(page *Page) ([]string, []string) {
+	var layoutNames []string
+	if page.Layout != "" {
+		layoutNames = formatNames(page.Layout, page.Format)
+		if page.primary == nil {
+			layoutNames = append(layoutNames, page.Layout)
 		}
 	}
-	return append(t.baseOrdered, out...)
+
+	name := SingleTemplate
+	if page.Kind == KindSection || page.Kind == KindList {
+		name = ListTemplate
+	}
+
+	var names []string
+	for dir := page.SectionPath; dir != "/" && dir != ""; dir = parentSection(dir) {
+		names = append(names, formatNames(path.Join(strings.TrimPrefix(dir, "/"), name), page.Format)...)
+	}
+	names = append(names, formatNames(path.Join(DefaultTemplates, name), page.Format)...)
+	if page.Kind == KindSection || page.Kind == KindList {
+		names = append(names, formatNames(name, page.Format)...)
+	}
+	return layoutNames, names
+}
+
+// formatNames returns the names of a template for a format, the most specific
+// first: single.json.json, then single.json.
+func formatNames(name string, format OutputFormat) []string {
+	ext := format.Extension
+	if ext == "" {
+		ext = HTML
+	}
+	base := strings.TrimSuffix(name, path.Ext(name))
+
+	var names []string
+	if format.Name != "" {
+		names = append(names, base+"."+format.Name+ext)
+	}
+	return append(names, base+ext)
+}
+
+// formatName returns the name of a template for the extension of a format,
+// e.g. single.json.
+func formatName(name string, format OutputFormat) string {
+	names := formatNames(name, format)
+	return names[len(names)-1]
+}
+
+// resolve returns the file of a template name, e.g. "_default/single.html".
+func (t *Templates) resolve(name string) (string, bool) {
+	file, ok := t.files[path.Clean(filepath.ToSlash(name))]
+	return file, ok
 }
 
 type File string
@@ -126,11 +259,16 @@ func (s SiteFiles) Get(entry string) *FileContainer {
 }
 
 type Assis struct {
-	config    *Config
-	templates Templates
-	plugins   []interface{}
-	container SiteFiles
-	logger    *zap.Logger
+	config      *Config
+	templates   Templates
+	plugins     []interface{}
+	registry    *PluginRegistry
+	registryErr error
+	ctx         context.Context
+	container   SiteFiles
+	site        *Site
+	logger      *zap.Logger
+	parsed      *parseLog
 }
 
 func NewAssis(config *Config, plugins []interface{}, logger *zap.Logger) Assis {
@@ -138,39 +276,139 @@ func NewAssis(config *Config, plugins []interface{}, logger *zap.Logger) Assis {
 	logger.Info(fmt.Sprintf("Content dir: %s", config.Content))
 	logger.Info(fmt.Sprintf("Output dir: %s", config.Output))
 	logger.Info(fmt.Sprintf("Template dir: %s", config.Template))
+	logger.Info(fmt.Sprintf("Data dir: %s", config.Data))
 
+	registry, err := NewPluginRegistry(plugins...)
+	registry.setOrder(config.pluginOrder())
 	return Assis{
-		config:    config,
-		plugins:   plugins,
-		container: SiteFiles{},
-		logger:    logger,
-		templates: NewTemplates(config),
+		config:      config,
+		plugins:     plugins,
+		registry:    registry,
+		registryErr: err,
+		ctx:         context.Background(),
+		container:   SiteFiles{},
+		site:        NewSite(config),
+		logger:      logger,
+		templates:   NewTemplates(config),
 	}
 }
 
-func (a *Assis) LoadTemplates(path string, d fs.DirEntry, err error) error {
+// orderedPlugins returns the plugins in the order of a hook, as the registry
+// sorts them.
+func (a *Assis) orderedPlugins(hook string) ([]interface{}, error) {
+	if a.registryErr != nil {
+		return nil, a.registryErr
+	}
+	return a.registry.Ordered(hook)
+}
+
+// runHook calls run with each plugin, in the order of the hook, stopping at
+// the first error or when the build is cancelled.
+func (a *Assis) runHook(hook string, run func(plugin interface{}) error) error {
+	plugins, err := a.orderedPlugins(hook)
 	if err != nil {
 		return err
 	}
-	if d.IsDir() || filepath.Ext(path) != HTML {
-		return nil
+	for _, plugin := range plugins {
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		if err := run(plugin); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// WithContext makes the build stop once ctx is cancelled, between pages and
+// between hooks.
+func (a *Assis) WithContext(ctx context.Context) {
+	a.ctx = ctx
+}
 
-	if filepath.ToSlash(d.Name()) == a.config.Template.Layout {
-		a.templates.baseTemplate = filepath.ToSlash(path)
-		a.logger.Info(fmt.Sprintf("Loaded base template: %s", path))
+// BuildContext returns what the lifecycle hooks of the build get.
+func (a *Assis) BuildContext() *BuildContext {
+	return &BuildContext{Context: a.ctx, Config: a.config, Logger: a.logger, Site: a.site}
+}
+
+// WatchEvent runs the OnWatchEvent hooks for a file changed in watch mode.
+func (a *Assis) WatchEvent(changed string) error {
+	return a.runHook(HookWatchEvent, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginWatchEvent); ok {
+			return plugin.OnWatchEvent(a.BuildContext(), changed)
+		}
 		return nil
+	})
+}
+
+// UseTemplateCache makes the build reuse the templates parsed by a previous
+// one, as serve does in watch mode.
+func (a *Assis) UseTemplateCache(cache *TemplateCache) {
+	a.templates.cache = cache
+}
+
+// LoadTemplates reads the template folders of the themes, the last theme
+// first, then the one of the site, so each file overrides the file of the
+// same path in the layers below.
+func (a *Assis) LoadTemplates() error {
+	layers := a.config.templateLayers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		if i > 0 {
+			if exists, _ := Exists(layer.Path); !exists {
+				continue
+			}
+		}
+
+		dirs := []string{layer.Path}
+		if !strings.HasPrefix(layer.Partials+"/", layer.Path+"/") {
+			if exists, _ := Exists(layer.Partials); exists {
+				dirs = append(dirs, layer.Partials)
+			}
+		}
+		for _, dir := range dirs {
+			if err := filepath.WalkDir(dir, a.loadTemplate(layer)); err != nil {
+				return err
+			}
+		}
 	}
+	a.templates.orderBaseTemplate()
+	return nil
+}
 
-	if strings.Contains(filepath.ToSlash(path), a.config.Template.Partials) {
-		a.templates.partials = append(a.templates.partials, path)
-		a.logger.Info(fmt.Sprintf("Loaded partial: %s", path))
+// loadTemplate registers the templates of one layer: its base layout, its
+// partials and the templates pages are looked up from. Files are templates
+// when their extension is the one of an output format.
+func (a *Assis) loadTemplate(layer templateLayer) fs.WalkDirFunc {
+	formats := a.config.AllOutputFormats()
+	return func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, ok := formats.ByExtension(filepath.Ext(path)); !ok {
+			return nil
+		}
+		path = filepath.ToSlash(path)
+
+		if d.Name() == a.config.Template.Layout {
+			a.templates.baseTemplate = path
+			a.logger.Info(fmt.Sprintf("Loaded base template: %s", path))
+			return nil
+		}
+
+		if strings.HasPrefix(path, layer.Partials+"/") {
+			a.templates.addPartial(strings.TrimPrefix(path, layer.Partials+"/"), path)
+			a.logger.Info(fmt.Sprintf("Loaded partial: %s", path))
+			return nil
+		}
+
+		a.templates.files[strings.TrimPrefix(path, layer.Path+"/")] = path
+		a.logger.Info(fmt.Sprintf("Loaded template: %s", path))
 		return nil
 	}
-
-	a.templates.files = append(a.templates.files, filepath.ToSlash(path))
-	a.logger.Info(fmt.Sprintf("Loaded template: %s", path))
-	return nil
 }
 
 func (a *Assis) LoadContent(path string, d fs.DirEntry, err error) error {
@@ -191,6 +429,34 @@ func (a *Assis) LoadContent(path string, d fs.DirEntry, err error) error {
 func (a *Assis) LoadFilesAsync() error {
 	a.logger.Info("Run LoadFiles task")
 
+	if _, err := a.orderedPlugins(""); err != nil {
+		return err
+	}
+
+	err := a.runHook(HookConfigLoaded, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginConfigLoaded); ok {
+			return plugin.OnConfigLoaded(a.BuildContext())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.site = NewSite(a.config)
+	if err := a.config.applyThemes(a.site); err != nil {
+		return err
+	}
+
+	err = a.runHook(HookBeforeLoad, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginBeforeLoad); ok {
+			return plugin.BeforeLoad(a.BuildContext())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
 	fatalErrors := make(chan error)
 	wgDone := make(chan bool)
 	wg := sync.WaitGroup{}
@@ -198,11 +464,9 @@ func (a *Assis) LoadFilesAsync() error {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := filepath.WalkDir(a.config.Template.Path, a.LoadTemplates)
-		if err != nil {
+		if err := a.LoadTemplates(); err != nil {
 			fatalErrors <- err
 		}
-		a.templates.orderBaseTemplate()
 	}()
 
 	wg.Add(1)
@@ -214,6 +478,18 @@ func (a *Assis) LoadFilesAsync() error {
 		}
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data, err := LoadData(a.config.Data)
+		if err != nil {
+			fatalErrors <- err
+			return
+		}
+		a.site.Data = data
+		a.logger.Info(fmt.Sprintf("Loaded data files from: %s", a.config.Data))
+	}()
+
 	go func() {
 		wg.Wait()
 		close(wgDone)
@@ -222,18 +498,23 @@ func (a *Assis) LoadFilesAsync() error {
 
 	select {
 	case <-wgDone:
+		if err := a.buildSite(); err != nil {
+			return err
+		}
+
 		a.logger.Info("Run AfterLoadFiles")
-		for _, plugin := range a.plugins {
-			switch plugin := plugin.(type) {
-			case PluginLoadFiles:
+		err := a.runHook(HookLoadFiles, func(plugin interface{}) error {
+			if plugin, ok := plugin.(PluginLoadFiles); ok {
 				start := time.Now()
-				if err := plugin.AfterLoadFiles(a.container); err != nil {
+				if err := plugin.AfterLoadFiles(a.site, a.container); err != nil {
 					return err
 				}
-				elapsed := time.Since(start)
-				a.logger.Info(fmt.Sprintf("PluginLoadFiles took %s", elapsed))
-				break
+				a.logger.Info(fmt.Sprintf("PluginLoadFiles took %s", time.Since(start)))
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 		break
 	case err := <-fatalErrors:
@@ -243,10 +524,136 @@ func (a *Assis) LoadFilesAsync() error {
 	return nil
 }
 
+// buildSite reads every content page into the site graph, lets plugins add
+// theirs and indexes the result, so rendering starts with the complete site.
+// Front matter defaults from _defaults files and section cascades are applied
+// before the pages are resolved.
+func (a *Assis) buildSite() error {
+	a.logger.Info("Build site graph")
+	var pages []*Page
+	var cascades []cascade
+	for _, container := range a.container {
+		for _, file := range container.files {
+			if !isDefaultsFile(file) {
+				continue
+			}
+			defaults, err := readDefaultsFile(a.config, container, file)
+			if err != nil {
+				return err
+			}
+			cascades = append(cascades, defaults...)
+		}
+
+		for _, file := range container.FilterExt([]string{HTML, MD}) {
+			page, err := newContentPage(a.config, container, file)
+			if err != nil {
+				return err
+			}
+			pages = append(pages, page)
+
+			if page.Kind == KindSection {
+				sectionCascades, err := newCascades(page.SectionPath, page.Source, page.Params["cascade"])
+				if err != nil {
+					return err
+				}
+				cascades = append(cascades, sectionCascades...)
+			}
+		}
+	}
+
+	applyCascades(a.config, pages, cascades)
+	for _, page := range pages {
+		page.resolve()
+		for key, source := range page.Inherited {
+			a.logger.Debug(fmt.Sprintf("%s: %s inherited from %s", page.Source, key, source))
+		}
+		err := a.runHook(HookContentParsed, func(plugin interface{}) error {
+			if plugin, ok := plugin.(PluginContentParsed); ok {
+				return plugin.OnContentParsed(a.BuildContext(), page)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		a.site.AddPage(page)
+	}
+
+	err := a.runHook(HookBuildSite, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginBuildSite); ok {
+			return plugin.OnBuildSite(a.site)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.site.Index()
+	formats := a.config.AllOutputFormats()
+	for _, page := range a.site.Pages {
+		if err := page.setOutputs(formats, a.config.Outputs); err != nil {
+			return err
+		}
+	}
+	if err := a.site.checkOutputs(); err != nil {
+		return err
+	}
+	if err := a.site.buildMenus(); err != nil {
+		return err
+	}
+	a.logger.Info(fmt.Sprintf("Site graph has %d pages in %d sections", len(a.site.Pages), len(a.site.Sections)))
+	return nil
+}
+
+// Generate builds the site in two phases: Collect, then Render.
 func (a *Assis) Generate() error {
 	a.logger.Info("Run Generate task")
-	generator := NewGenerator(a.templates, a.plugins)
-	if err := generator.Render(a.container); err != nil {
+	snapshot, err := a.Collect()
+	if err != nil {
+		return err
+	}
+	return a.Render(snapshot)
+}
+
+// Collect is the first phase of Generate: once LoadFilesAsync parsed every
+// page, it collects the articles and their collections into the snapshot the
+// render phase reads from.
+func (a *Assis) Collect() (*Snapshot, error) {
+	if err := a.ctx.Err(); err != nil {
+		return nil, err
+	}
+	a.logger.Info("Collect site snapshot")
+	return NewSnapshot(a.site), nil
+}
+
+// RenderPage renders the outputs of one page only, once LoadFilesAsync built
+// the site, from a snapshot of the whole site so it sees the same collections
+// as in a full build. The hooks of the generated files and of the end of the
+// build don't run. What is parsed for each output is kept for Explain.
+func (a *Assis) RenderPage(page *Page) error {
+	snapshot, err := a.Collect()
+	if err != nil {
+		return err
+	}
+	generator := newGenerator(a.templates, a.plugins, a.BuildContext(), snapshot)
+	generator.only = map[string]bool{}
+	for _, output := range page.Outputs() {
+		generator.only[output.Output] = true
+	}
+	if a.parsed == nil {
+		a.parsed = newParseLog()
+	}
+	generator.parsed = a.parsed
+	return generator.Render(a.site, a.container)
+}
+
+// Render is the second phase of Generate: it renders every page from the
+// snapshot, then runs the hooks of the generated files and of the end of the
+// build.
+func (a *Assis) Render(snapshot *Snapshot) error {
+	generator := newGenerator(a.templates, a.plugins, a.BuildContext(), snapshot)
+	if err := generator.Render(a.site, a.container); err != nil {
 		return err
 	}
 
@@ -268,14 +675,21 @@ func (a *Assis) Generate() error {
 	}
 
 	a.logger.Info("Run AfterGeneratedFiles")
-	for _, plugin := range a.plugins {
-		switch plugin := plugin.(type) {
-		case PluginGeneratedFiles:
-			if err := plugin.AfterGeneratedFiles(generated); err != nil {
-				return err
-			}
-			break
+	err = a.runHook(HookGeneratedFiles, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginGeneratedFiles); ok {
+			return plugin.AfterGeneratedFiles(a.site, generated)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return nil
+
+	a.logger.Info("Run AfterBuild")
+	return a.runHook(HookAfterBuild, func(plugin interface{}) error {
+		if plugin, ok := plugin.(PluginAfterBuild); ok {
+			return plugin.AfterBuild(a.BuildContext())
+		}
+		return nil
+	})
 }
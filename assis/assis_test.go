@@ -1,8 +1,14 @@
 package assis
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +42,224 @@ func TestAssis_Generate(t *testing.T) {
 	err := assis.Generate()
 	assert.NoError(t, err)
 }
+
+func TestAssis_Themes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := NewDefaultConfig("./mock/_site")
+	cfg.Theme = Themes{"base", "extra"}
+
+	static := NewStaticFilesPlugin(cfg, []string{".css", ".js"}, logger)
+	site := NewAssis(cfg, []interface{}{static}, logger)
+	assert.NoError(t, site.LoadFilesAsync())
+
+	t.Run("config defaults from the theme", func(t *testing.T) {
+		assert.Equal(t, "Assis theme", site.site.Title)
+		assert.Equal(t, "Made with assis", site.site.Params["footer"])
+		assert.Len(t, site.site.Menus["footer"], 1)
+	})
+
+	t.Run("theme defaults stay out of the config", func(t *testing.T) {
+		assert.Empty(t, cfg.Title)
+		assert.NotContains(t, cfg.Params, "footer")
+		assert.NotContains(t, cfg.Menus, "footer")
+
+		rebuilt := *cfg
+		rebuilt.Theme = Themes{}
+		without := NewAssis(&rebuilt, []interface{}{}, logger)
+		assert.NoError(t, without.LoadFilesAsync())
+		assert.NotEqual(t, "Assis theme", without.site.Title)
+		assert.NotContains(t, without.site.Params, "footer")
+	})
+
+	t.Run("site templates override theme templates by path", func(t *testing.T) {
+		assert.Equal(t, "mock/_site/template/layout.html", site.templates.baseTemplate)
+		assert.Contains(t, site.templates.baseOrdered, "mock/_site/themes/base/partials/footer.html")
+
+		list, _ := site.templates.resolve("_default/list.html")
+		assert.Equal(t, "mock/_site/template/_default/list.html", list)
+
+		page, err := site.templates.Lookup(&Page{Layout: "theme_page.html"})
+		assert.NoError(t, err)
+		assert.Equal(t, "mock/_site/themes/base/template/theme_page.html", page, "the first theme wins")
+	})
+
+	t.Run("static files resolve through the layers", func(t *testing.T) {
+		b, err := ioutil.ReadFile("./mock/_site/output/css/theme.css")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "black")
+
+		b, err = ioutil.ReadFile("./mock/_site/output/css/test.css")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "margin")
+
+		assert.FileExists(t, "./mock/_site/output/js/extra.js")
+	})
+
+	t.Run("unknown theme", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Theme = Themes{"missing"}
+		missing := NewAssis(cfg, nil, logger)
+		assert.Error(t, missing.LoadFilesAsync())
+		assert.Error(t, checkConfigThemes(*cfg))
+	})
+
+	t.Run("theme setting takes a name or a list", func(t *testing.T) {
+		var cfg Config
+		assert.NoError(t, json.Unmarshal([]byte(`{"theme": "base"}`), &cfg))
+		assert.Equal(t, Themes{"base"}, cfg.Theme)
+		assert.NoError(t, json.Unmarshal([]byte(`{"theme": ["base", "extra"]}`), &cfg))
+		assert.Equal(t, Themes{"base", "extra"}, cfg.Theme)
+	})
+}
+
+func TestAssis_Explain(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	cfg := NewDefaultConfig("./mock/_site")
+	site := NewAssis(cfg, []interface{}{NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)}, logger)
+	assert.NoError(t, site.LoadFilesAsync())
+
+	t.Run("page of a content file", func(t *testing.T) {
+		page, err := site.Page("mock/_site/content/about.html")
+		assert.NoError(t, err)
+		assert.Equal(t, "about.html", page.Permalink)
+
+		_, err = site.Page("mock/_site/content/missing.html")
+		assert.Error(t, err)
+	})
+
+	t.Run("pages are explained once rendered", func(t *testing.T) {
+		page, _ := site.Page("mock/_site/content/index.html")
+		_, err := site.Explain(page)
+		assert.Error(t, err)
+	})
+
+	t.Run("templates and blocks of an HTML page", func(t *testing.T) {
+		page, _ := site.Page("mock/_site/content/about.html")
+		assert.NoError(t, site.RenderPage(page))
+		explanation, err := site.Explain(page)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "_default/single.html", explanation.Resolved)
+		assert.Equal(t, "mock/_site/template/_default/single.html", explanation.Template)
+		assert.Equal(t, "layout", explanation.Entry)
+
+		var roles []string
+		for _, file := range explanation.Files {
+			roles = append(roles, file.Role)
+		}
+		assert.Equal(t, []string{"base", "partial", "template", "content"}, roles)
+
+		for _, block := range explanation.Blocks {
+			if block.Name == "body" {
+				assert.Equal(t, "mock/_site/content/about.html", block.File)
+				assert.Equal(t, []string{"mock/_site/template/_default/single.html"}, block.Overridden)
+			}
+		}
+		assert.Contains(t, explanation.Data, `"Title": "Sobre"`)
+		assert.Contains(t, explanation.String(), "> _default/single.html")
+	})
+
+	t.Run("article of a plain text format", func(t *testing.T) {
+		page, _ := site.Page("mock/_site/content/articles/article1.md")
+		assert.NoError(t, site.RenderPage(page))
+		explanation, err := site.Explain(page.AlternativeOutputs()[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "_default/single.json.json", explanation.Resolved)
+		assert.Equal(t, "mock/_site/template/_default/single.json.json", explanation.Entry)
+		assert.Contains(t, explanation.Data, `"Authors": [`)
+	})
+}
+
+type hooksPlugin struct {
+	calls *[]string
+}
+
+func (h hooksPlugin) OnConfigLoaded(ctx *BuildContext) error {
+	*h.calls = append(*h.calls, "config_loaded")
+	ctx.Config.Params["hooked"] = true
+	return nil
+}
+
+func (h hooksPlugin) BeforeLoad(ctx *BuildContext) error {
+	*h.calls = append(*h.calls, "before_load")
+	return nil
+}
+
+func (h hooksPlugin) OnContentParsed(ctx *BuildContext, page *Page) error {
+	if page.Source == "mock/_site/content/about.html" {
+		*h.calls = append(*h.calls, "content_parsed")
+		page.Title = "Hooked"
+	}
+	return nil
+}
+
+func (h hooksPlugin) OnPageRendered(ctx *BuildContext, page *Page, output string, b []byte) ([]byte, error) {
+	if page != nil && page.Source == "mock/_site/content/about.html" {
+		*h.calls = append(*h.calls, "page_rendered")
+		return append(b, []byte("<!-- hooked -->")...), nil
+	}
+	return b, nil
+}
+
+func (h hooksPlugin) AfterBuild(ctx *BuildContext) error {
+	*h.calls = append(*h.calls, "after_build")
+	return nil
+}
+
+func (h hooksPlugin) OnWatchEvent(ctx *BuildContext, changed string) error {
+	*h.calls = append(*h.calls, "watch_event "+changed)
+	return nil
+}
+
+func TestAssis_Hooks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("run through the build", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Params = map[string]interface{}{}
+		var calls []string
+		site := NewAssis(cfg, []interface{}{hooksPlugin{&calls}, NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)}, logger)
+
+		assert.NoError(t, site.WatchEvent("content/about.html"))
+		assert.NoError(t, site.LoadFilesAsync())
+		assert.NoError(t, site.Generate())
+		assert.Equal(t, []string{"watch_event content/about.html", "config_loaded", "before_load", "content_parsed", "page_rendered", "after_build"}, calls)
+		assert.Equal(t, true, cfg.Params["hooked"])
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "<title>Hooked - ")
+		assert.True(t, strings.HasSuffix(string(b), "<!-- hooked -->"))
+	})
+
+	t.Run("render one page only", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Params = map[string]interface{}{}
+		cfg.Output = t.TempDir()
+		var calls []string
+		site := NewAssis(cfg, []interface{}{hooksPlugin{&calls}, NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)}, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+
+		page, err := site.Page("mock/_site/content/about.html")
+		assert.NoError(t, err)
+		assert.NoError(t, site.RenderPage(page))
+		assert.Equal(t, []string{"config_loaded", "before_load", "content_parsed", "page_rendered"}, calls)
+
+		b, err := ioutil.ReadFile(page.Output)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(b), "<!-- hooked -->"))
+		_, err = os.Stat(filepath.Join(cfg.Output, "index.html"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("stop once the context is cancelled", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		var calls []string
+		site := NewAssis(cfg, []interface{}{hooksPlugin{&calls}}, logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		site.WithContext(ctx)
+		assert.ErrorIs(t, site.LoadFilesAsync(), context.Canceled)
+		assert.Empty(t, calls)
+	})
+}
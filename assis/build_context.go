@@ -0,0 +1,61 @@
+package assis
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// BuildContext is what the lifecycle hooks get: the context of the build,
+// cancelled to stop it, the config, the logger and the site graph, complete
+// once the content is parsed.
+type BuildContext struct {
+	Context context.Context
+	Config  *Config
+	Logger  *zap.Logger
+	Site    *Site
+}
+
+// Err tells whether the build was cancelled.
+func (b *BuildContext) Err() error {
+	if b.Context == nil {
+		return nil
+	}
+	return b.Context.Err()
+}
+
+// PluginConfigLoaded runs once the config is complete, themes applied, and can
+// still change it.
+type PluginConfigLoaded interface {
+	OnConfigLoaded(*BuildContext) error
+}
+
+// PluginBeforeLoad runs before the templates and content are read.
+type PluginBeforeLoad interface {
+	BeforeLoad(*BuildContext) error
+}
+
+// PluginContentParsed runs for each content file once its page is parsed and
+// its front matter resolved, before it is added to the site graph.
+type PluginContentParsed interface {
+	OnContentParsed(*BuildContext, *Page) error
+}
+
+// PluginPageRendered runs for each rendered output with its bytes, and
+// returns the bytes written in their place. The page is nil for outputs not
+// rendered from a page.
+type PluginPageRendered interface {
+	OnPageRendered(ctx *BuildContext, page *Page, output string, b []byte) ([]byte, error)
+}
+
+// PluginAfterBuild runs once the site is generated and the generated files
+// processed.
+type PluginAfterBuild interface {
+	AfterBuild(*BuildContext) error
+}
+
+// PluginWatchEvent runs in watch mode for each changed file, before the site
+// is generated again.
+type PluginWatchEvent interface {
+	OnWatchEvent(ctx *BuildContext, changed string) error
+}
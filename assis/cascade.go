@@ -0,0 +1,160 @@
+package assis
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultsFile is the name, without extension, of the files holding front
+// matter defaults for every file under their directory.
+const DefaultsFile = "_defaults"
+
+// cascadeGlob is the key narrowing a cascade entry to the files matching it.
+const cascadeGlob = "_glob"
+
+// cascade is one set of front matter defaults, from a _defaults file or from
+// the cascade block of a section index.
+type cascade struct {
+	section string
+	glob    string
+	values  map[string]interface{}
+	source  string
+}
+
+// isDefaultsFile tells whether a file of the content folder holds defaults:
+// _defaults, _defaults.yaml, _defaults.yml or _defaults.json.
+func isDefaultsFile(file File) bool {
+	name := string(file)
+	switch filepath.Ext(name) {
+	case "", ".yaml", ".yml", ".json":
+		return strings.TrimSuffix(name, filepath.Ext(name)) == DefaultsFile
+	}
+	return false
+}
+
+func readDefaultsFile(config *Config, container *FileContainer, file File) ([]cascade, error) {
+	source := filepath.ToSlash(container.FullFilename(file))
+	b, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if filepath.Ext(source) == ".json" {
+		err = json.Unmarshal(b, &raw)
+	} else {
+		err = yaml.Unmarshal(b, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	rel, err := filepath.Rel(config.Content, container.entry)
+	if err != nil {
+		return nil, err
+	}
+	return newCascades(sectionPath(rel), source, raw)
+}
+
+// newCascades reads a block of defaults, either one map applying to every file
+// or a list of maps, each optionally narrowed by a _glob.
+func newCascades(section, source string, raw interface{}) ([]cascade, error) {
+	var entries []interface{}
+	switch value := raw.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		entries = value
+	default:
+		entries = []interface{}{value}
+	}
+
+	var out []cascade
+	for _, entry := range entries {
+		values, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: cascade entries must be maps of front matter values", source)
+		}
+
+		c := cascade{section: section, source: source, values: map[string]interface{}{}}
+		for key, value := range values {
+			key = strings.ToLower(key)
+			if key == cascadeGlob {
+				c.glob = fmt.Sprint(value)
+				if _, err := path.Match(c.glob, ""); err != nil {
+					return nil, fmt.Errorf("%s: %s %q: %w", source, cascadeGlob, c.glob, err)
+				}
+				continue
+			}
+			c.values[key] = normalizeParam(value)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// matches tells whether the cascade applies to a page: the page must be under
+// the cascade section and, with a glob, its path relative to that section, or
+// its file name for globs without "/", must match it.
+func (c cascade) matches(config *Config, page *Page) bool {
+	if page.Source == c.source {
+		return false
+	}
+	if c.section != "/" && page.SectionPath != c.section && !strings.HasPrefix(page.SectionPath, c.section+"/") {
+		return false
+	}
+	if c.glob == "" {
+		return true
+	}
+
+	root := strings.TrimSuffix(config.Content+c.section, "/") + "/"
+	rel := strings.TrimPrefix(page.Source, root)
+	if ok, _ := path.Match(c.glob, rel); ok {
+		return true
+	}
+	if !strings.Contains(c.glob, "/") {
+		ok, _ := path.Match(c.glob, path.Base(rel))
+		return ok
+	}
+	return false
+}
+
+// applyCascades fills the front matter each page leaves unset. Values from a
+// nearer directory win over the ones of its parents and, in one directory, the
+// cascade block of the section index wins over the _defaults file.
+func applyCascades(config *Config, pages []*Page, cascades []cascade) {
+	sort.SliceStable(cascades, func(i, j int) bool {
+		di, dj := strings.Count(cascades[i].section, "/"), strings.Count(cascades[j].section, "/")
+		if cascades[i].section == "/" {
+			di = 0
+		}
+		if cascades[j].section == "/" {
+			dj = 0
+		}
+		if di != dj {
+			return di > dj
+		}
+		return !isDefaultsFile(File(path.Base(cascades[i].source))) && isDefaultsFile(File(path.Base(cascades[j].source)))
+	})
+
+	for _, page := range pages {
+		for _, c := range cascades {
+			if !c.matches(config, page) {
+				continue
+			}
+			for key, value := range c.values {
+				if _, ok := page.Params[key]; ok {
+					continue
+				}
+				page.Params[key] = value
+				page.Inherited[key] = c.source
+			}
+		}
+	}
+}
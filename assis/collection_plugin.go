@@ -0,0 +1,561 @@
+package assis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "02/01/2006"}
+
+type Group struct {
+	Key   interface{}
+	Items interface{}
+}
+
+type CollectionPlugin struct{}
+
+func NewCollectionPlugin() CollectionPlugin {
+	return CollectionPlugin{}
+}
+
+func (c CollectionPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "collections", Version: builtinVersion}
+}
+
+func (c CollectionPlugin) OnRegisterCustomFunction() map[string]interface{} {
+	return map[string]interface{}{
+		"where":     c.where,
+		"sortBy":    c.sortBy,
+		"groupBy":   c.groupBy,
+		"first":     c.first,
+		"after":     c.after,
+		"union":     c.union,
+		"intersect": c.intersect,
+		"uniq":      c.uniq,
+	}
+}
+
+// where filters a list by a field: where "Field" [op] value list.
+// The list comes last so it can be used at the end of a pipeline.
+func (c CollectionPlugin) where(key string, args ...interface{}) (interface{}, error) {
+	op := "=="
+	var match, list interface{}
+	switch len(args) {
+	case 2:
+		match, list = args[0], args[1]
+	case 3:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where: operator must be a string, got %T", args[0])
+		}
+		op, match, list = s, args[1], args[2]
+	default:
+		return nil, fmt.Errorf("where: expected 2 or 3 arguments after the key, got %d", len(args))
+	}
+
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+
+	out := reflect.MakeSlice(items.Type(), 0, items.Len())
+	for i := 0; i < items.Len(); i++ {
+		value, _ := fieldValue(items.Index(i), key)
+		ok, err := matches(op, value, match)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = reflect.Append(out, items.Index(i))
+		}
+	}
+	return out.Interface(), nil
+}
+
+// sortBy sorts a copy of a list by a field: sortBy "Field" ["asc"|"desc"] list.
+func (c CollectionPlugin) sortBy(key string, args ...interface{}) (interface{}, error) {
+	dir := "asc"
+	var list interface{}
+	switch len(args) {
+	case 1:
+		list = args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("sortBy: order must be a string, got %T", args[0])
+		}
+		dir, list = strings.ToLower(s), args[1]
+	default:
+		return nil, fmt.Errorf("sortBy: expected 1 or 2 arguments after the key, got %d", len(args))
+	}
+	if dir != "asc" && dir != "desc" {
+		return nil, fmt.Errorf("sortBy: unknown order %q", dir)
+	}
+
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, items.Len())
+	for i := range values {
+		values[i], _ = fieldValue(items.Index(i), key)
+	}
+	index := make([]int, items.Len())
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		cmp, _ := compareValues(values[index[i]], values[index[j]])
+		if dir == "desc" {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	sorted := reflect.MakeSlice(items.Type(), 0, items.Len())
+	for _, i := range index {
+		sorted = reflect.Append(sorted, items.Index(i))
+	}
+	return sorted.Interface(), nil
+}
+
+// groupBy splits a list by the value of a field, keeping the order in which
+// keys first appear: groupBy "Field" [dateLayout] list. When a date layout is
+// given the field is parsed as a date and formatted with it, so
+// groupBy "Date" "2006" groups by year.
+func (c CollectionPlugin) groupBy(key string, args ...interface{}) ([]Group, error) {
+	layout := ""
+	var list interface{}
+	switch len(args) {
+	case 1:
+		list = args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("groupBy: date layout must be a string, got %T", args[0])
+		}
+		layout, list = s, args[1]
+	default:
+		return nil, fmt.Errorf("groupBy: expected 1 or 2 arguments after the key, got %d", len(args))
+	}
+
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []interface{}
+	groups := map[interface{}]reflect.Value{}
+	for i := 0; i < items.Len(); i++ {
+		value, _ := fieldValue(items.Index(i), key)
+		if layout != "" {
+			date, ok := toDate(value)
+			if !ok {
+				return nil, fmt.Errorf("groupBy: %q is not a date: %v", key, value)
+			}
+			value = date.Format(layout)
+		}
+		k := identity(reflect.ValueOf(value))
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, value)
+			groups[k] = reflect.MakeSlice(items.Type(), 0, 1)
+		}
+		groups[k] = reflect.Append(groups[k], items.Index(i))
+	}
+
+	out := make([]Group, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, Group{Key: k, Items: groups[identity(reflect.ValueOf(k))].Interface()})
+	}
+	return out, nil
+}
+
+func (c CollectionPlugin) first(size int, list interface{}) (interface{}, error) {
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("first: size must not be negative")
+	}
+	if size > items.Len() {
+		size = items.Len()
+	}
+	return items.Slice(0, size).Interface(), nil
+}
+
+func (c CollectionPlugin) after(size int, list interface{}) (interface{}, error) {
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("after: size must not be negative")
+	}
+	if size > items.Len() {
+		size = items.Len()
+	}
+	return items.Slice(size, items.Len()).Interface(), nil
+}
+
+// union returns the items of list followed by the items of other that are not
+// already in it.
+func (c CollectionPlugin) union(other, list interface{}) (interface{}, error) {
+	a, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toSlice(other)
+	if err != nil {
+		return nil, err
+	}
+
+	out := reflect.MakeSlice(commonType(a, b), 0, a.Len()+b.Len())
+	seen := map[interface{}]bool{}
+	for _, items := range []reflect.Value{a, b} {
+		for i := 0; i < items.Len(); i++ {
+			k := identity(items.Index(i))
+			if !seen[k] {
+				seen[k] = true
+				out = reflect.Append(out, items.Index(i))
+			}
+		}
+	}
+	return out.Interface(), nil
+}
+
+// intersect returns the items of list that are also in other.
+func (c CollectionPlugin) intersect(other, list interface{}) (interface{}, error) {
+	a, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toSlice(other)
+	if err != nil {
+		return nil, err
+	}
+
+	inOther := map[interface{}]bool{}
+	for i := 0; i < b.Len(); i++ {
+		inOther[identity(b.Index(i))] = true
+	}
+
+	out := reflect.MakeSlice(commonType(a, b), 0, a.Len())
+	seen := map[interface{}]bool{}
+	for i := 0; i < a.Len(); i++ {
+		k := identity(a.Index(i))
+		if inOther[k] && !seen[k] {
+			seen[k] = true
+			out = reflect.Append(out, a.Index(i))
+		}
+	}
+	return out.Interface(), nil
+}
+
+func (c CollectionPlugin) uniq(list interface{}) (interface{}, error) {
+	items, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+
+	out := reflect.MakeSlice(items.Type(), 0, items.Len())
+	seen := map[interface{}]bool{}
+	for i := 0; i < items.Len(); i++ {
+		k := identity(items.Index(i))
+		if !seen[k] {
+			seen[k] = true
+			out = reflect.Append(out, items.Index(i))
+		}
+	}
+	return out.Interface(), nil
+}
+
+func toSlice(list interface{}) (reflect.Value, error) {
+	if list == nil {
+		return reflect.ValueOf([]interface{}{}), nil
+	}
+	v := reflect.ValueOf(list)
+	switch v.Kind() {
+	case reflect.Slice:
+		return v, nil
+	case reflect.Array:
+		out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+		reflect.Copy(out, v)
+		return out, nil
+	}
+	return reflect.Value{}, fmt.Errorf("expected a list, got %T", list)
+}
+
+func commonType(a, b reflect.Value) reflect.Type {
+	if a.Type() == b.Type() {
+		return a.Type()
+	}
+	return reflect.TypeOf([]interface{}{})
+}
+
+// fieldValue resolves a dotted path such as "Title" or "Params.author" on a
+// struct, map or zero argument method.
+func fieldValue(item reflect.Value, path string) (interface{}, bool) {
+	v := item
+	for _, name := range strings.Split(path, ".") {
+		if m := method(v, name); m.IsValid() {
+			v = m.Call(nil)[0]
+			continue
+		}
+
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			f := v.FieldByName(name)
+			if !f.IsValid() || !f.CanInterface() {
+				return nil, false
+			}
+			v = f
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			f := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !f.IsValid() {
+				f = v.MapIndex(reflect.ValueOf(strings.ToLower(name)).Convert(v.Type().Key()))
+			}
+			if !f.IsValid() {
+				return nil, false
+			}
+			v = f
+		default:
+			return nil, false
+		}
+	}
+
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+func method(v reflect.Value, name string) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Value{}
+	}
+	m := v.MethodByName(name)
+	if !m.IsValid() && v.Kind() == reflect.Interface && !v.IsNil() {
+		m = v.Elem().MethodByName(name)
+	}
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return reflect.Value{}
+	}
+	return m
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// identity returns a comparable key for an item. Items with a Permalink are
+// identified by it, so the same page reached through two collections is
+// treated as one.
+func identity(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if d := indirect(v); d.Kind() == reflect.Struct {
+		if f := d.FieldByName("Permalink"); f.IsValid() && f.Kind() == reflect.String {
+			return "permalink:" + f.String()
+		}
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Type().Comparable() {
+		return v.Interface()
+	}
+	return fmt.Sprintf("%#v", v.Interface())
+}
+
+func matches(op string, value, match interface{}) (bool, error) {
+	switch strings.ToLower(op) {
+	case "=", "==", "eq":
+		cmp, ok := compareValues(value, match)
+		return ok && cmp == 0, nil
+	case "!=", "<>", "ne":
+		cmp, ok := compareValues(value, match)
+		return !ok || cmp != 0, nil
+	case ">", "gt":
+		cmp, ok := compareValues(value, match)
+		return ok && cmp > 0, nil
+	case ">=", "ge":
+		cmp, ok := compareValues(value, match)
+		return ok && cmp >= 0, nil
+	case "<", "lt":
+		cmp, ok := compareValues(value, match)
+		return ok && cmp < 0, nil
+	case "<=", "le":
+		cmp, ok := compareValues(value, match)
+		return ok && cmp <= 0, nil
+	case "in":
+		return contains(match, value)
+	case "not in":
+		found, err := contains(match, value)
+		return !found, err
+	case "contains":
+		return contains(value, match)
+	case "intersect":
+		list, err := toSlice(match)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < list.Len(); i++ {
+			found, err := contains(value, list.Index(i).Interface())
+			if err != nil || found {
+				return found, err
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("where: unknown operator %q", op)
+}
+
+func contains(list, value interface{}) (bool, error) {
+	if list == nil {
+		return false, nil
+	}
+	if s, ok := list.(string); ok {
+		v, ok := value.(string)
+		return ok && strings.Contains(s, v), nil
+	}
+	items, err := toSlice(list)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < items.Len(); i++ {
+		if cmp, ok := compareValues(items.Index(i).Interface(), value); ok && cmp == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compareValues orders two field values. Numbers are compared numerically,
+// also when one side is a numeric string, so front matter values such as
+// "weight: 10" sort as expected. The boolean is false when the values can't
+// be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0, true
+		case a == nil:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := toDate(b); ok {
+			return compareOrdered(ta.Unix(), tb.Unix()), true
+		}
+	}
+
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum && bNum {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if ba, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			switch {
+			case ba == bb:
+				return 0, true
+			case bb:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	sa, aOk := toString(a)
+	sb, bOk := toString(b)
+	if aOk && bOk {
+		return strings.Compare(sa, sb), true
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+func compareOrdered(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(rv.String()), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case fmt.Stringer:
+		return s.String(), true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+	return "", false
+}
+
+func toDate(v interface{}) (time.Time, bool) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(d)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
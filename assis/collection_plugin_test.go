@@ -0,0 +1,101 @@
+package assis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func collectionFixture() []Article {
+	return []Article{
+		{Page: &Page{ID: "a", Permalink: "articles/a.html", Title: "Banana", Date: "2021-03-01", Params: map[string]interface{}{"weight": "10", "author": "ana"}}, Tags: Tags{"go"}},
+		{Page: &Page{ID: "b", Permalink: "articles/b.html", Title: "Apple", Date: "2020-05-01", Params: map[string]interface{}{"weight": "2", "author": "bob"}}, Tags: Tags{"web"}},
+		{Page: &Page{ID: "c", Permalink: "articles/c.html", Title: "Cherry", Date: "2021-01-01", Params: map[string]interface{}{"weight": "7", "author": "ana"}}, Tags: Tags{"go", "web"}},
+	}
+}
+
+func ids(list interface{}) []string {
+	var out []string
+	for _, a := range list.([]Article) {
+		out = append(out, a.ID)
+	}
+	return out
+}
+
+func TestCollectionPlugin_Where(t *testing.T) {
+	c := NewCollectionPlugin()
+	articles := collectionFixture()
+
+	t.Run("equality on nested params", func(t *testing.T) {
+		out, err := c.where("Params.author", "ana", articles)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "c"}, ids(out))
+	})
+
+	t.Run("numeric comparison on string params", func(t *testing.T) {
+		out, err := c.where("Params.weight", ">=", 7, articles)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "c"}, ids(out))
+	})
+
+	t.Run("tag filtering", func(t *testing.T) {
+		out, err := c.where("Tags", "contains", "web", articles)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, ids(out))
+
+		out, err = c.where("Title", "in", []string{"Apple", "Cherry"}, articles)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, ids(out))
+	})
+
+	t.Run("unknown operator", func(t *testing.T) {
+		_, err := c.where("Title", "~", "Apple", articles)
+		assert.Error(t, err)
+	})
+}
+
+func TestCollectionPlugin_SortAndGroup(t *testing.T) {
+	c := NewCollectionPlugin()
+	articles := collectionFixture()
+
+	out, err := c.sortBy("Title", articles)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a", "c"}, ids(out))
+
+	out, err = c.sortBy("Params.weight", "desc", articles)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "b"}, ids(out))
+	assert.Equal(t, []string{"a", "b", "c"}, ids(articles), "sortBy must not change its input")
+
+	groups, err := c.groupBy("Date", "2006", articles)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "2021", groups[0].Key)
+	assert.Equal(t, []string{"a", "c"}, ids(groups[0].Items))
+	assert.Equal(t, "2020", groups[1].Key)
+}
+
+func TestCollectionPlugin_SetOperations(t *testing.T) {
+	c := NewCollectionPlugin()
+	articles := collectionFixture()
+
+	head, _ := c.first(2, articles)
+	tail, _ := c.after(1, articles)
+	assert.Equal(t, []string{"a", "b"}, ids(head))
+	assert.Equal(t, []string{"b", "c"}, ids(tail))
+
+	out, err := c.union(tail, head)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(out))
+
+	out, err = c.intersect(tail, head)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(out))
+
+	out, err = c.uniq(append(articles, articles[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(out))
+
+	empty, err := c.first(5, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
@@ -9,15 +9,32 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type (
 	Config struct {
-		SiteRoot string   `json:"site_root"`
-		Output   string   `json:"output"`
-		Content  string   `json:"content"`
-		Template Template `json:"template"`
-		Server   Server   `json:"server"`
+		Title     string                 `json:"title"`
+		BaseURL   string                 `json:"base_url"`
+		Language  string                 `json:"language"`
+		Params    map[string]interface{} `json:"params"`
+		SiteRoot  string                 `json:"site_root"`
+		Output    string                 `json:"output"`
+		Content   string                 `json:"content"`
+		Data      string                 `json:"data"`
+		Template  Template               `json:"template"`
+		Server    Server                 `json:"server"`
+		DataPages []DataPage             `json:"data_pages"`
+		Menus     map[string][]MenuItem  `json:"menus"`
+		Strict    bool                   `json:"strict"`
+		Theme     Themes                 `json:"theme"`
+		ThemesDir string                 `json:"themes_dir"`
+
+		OutputFormats []OutputFormat      `json:"output_formats"`
+		Outputs       map[string][]string `json:"outputs"`
+
+		Plugins         []PluginConfig         `json:"plugins"`
+		ExternalPlugins []ExternalPluginConfig `json:"external_plugins"`
 	}
 
 	Template struct {
@@ -29,6 +46,33 @@ type (
 	Server struct {
 		Port string
 	}
+
+	// DataPage generates one page per record of a data file.
+	DataPage struct {
+		Name       string        `json:"name"`
+		Source     string        `json:"source"`
+		Template   string        `json:"template"`
+		Permalink  string        `json:"permalink"`
+		TitleField string        `json:"title_field"`
+		List       *DataPageList `json:"list"`
+	}
+
+	DataPageList struct {
+		Title     string `json:"title"`
+		Template  string `json:"template"`
+		Permalink string `json:"permalink"`
+	}
+
+	// MenuItem is one entry of a named menu. It links either to a page of the
+	// site, by permalink or source file, or to an URL.
+	MenuItem struct {
+		Identifier string `json:"identifier"`
+		Name       string `json:"name"`
+		URL        string `json:"url"`
+		Page       string `json:"page"`
+		Weight     int    `json:"weight"`
+		Parent     string `json:"parent"`
+	}
 )
 
 func (c Config) validate(configFolder string, configFile string) error {
@@ -58,6 +102,34 @@ func (c Config) validate(configFolder string, configFile string) error {
 		return errServer
 	}
 
+	if errDataPages := checkConfigDataPages(c.DataPages); errDataPages != nil {
+		return errDataPages
+	}
+
+	if errMenus := checkConfigMenus(c.Menus); errMenus != nil {
+		return errMenus
+	}
+
+	if errThemes := checkConfigThemes(c); errThemes != nil {
+		return errThemes
+	}
+
+	if errFormats := checkConfigOutputFormats(c.OutputFormats); errFormats != nil {
+		return errFormats
+	}
+
+	if errOutputs := checkConfigOutputs(c); errOutputs != nil {
+		return errOutputs
+	}
+
+	if errPlugins := checkConfigPlugins(c.Plugins); errPlugins != nil {
+		return errPlugins
+	}
+
+	if errPlugins := checkConfigExternalPlugins(c.ExternalPlugins); errPlugins != nil {
+		return errPlugins
+	}
+
 	return nil
 }
 
@@ -159,6 +231,145 @@ func checkConfigServer(server Server) error {
 	return nil
 }
 
+func checkConfigDataPages(dataPages []DataPage) error {
+
+	names := map[string]bool{}
+	for i, dataPage := range dataPages {
+		if len(dataPage.Name) == 0 {
+			return errors.New(fmt.Sprintf("you must define a name for data_pages[%d] in your config.json", i))
+		}
+
+		if names[dataPage.Name] {
+			return errors.New(fmt.Sprintf("data page '%s' is defined more than once in your config.json", dataPage.Name))
+		}
+		names[dataPage.Name] = true
+
+		if len(dataPage.Source) == 0 || len(dataPage.Template) == 0 || len(dataPage.Permalink) == 0 {
+			return errors.New(
+				fmt.Sprintf("you must define source, template and permalink for data page '%s' in your config.json", dataPage.Name))
+		}
+
+		if dataPage.List != nil && (len(dataPage.List.Template) == 0 || len(dataPage.List.Permalink) == 0) {
+			return errors.New(
+				fmt.Sprintf("you must define template and permalink for the list of data page '%s' in your config.json", dataPage.Name))
+		}
+	}
+
+	return nil
+}
+
+func checkConfigMenus(menus map[string][]MenuItem) error {
+
+	for menu, items := range menus {
+		for i, item := range items {
+			if len(item.URL) == 0 && len(item.Page) == 0 {
+				return errors.New(
+					fmt.Sprintf("you must define an url or a page for menus.%s[%d] in your config.json", menu, i))
+			}
+
+			if len(item.URL) > 0 && len(item.Page) > 0 {
+				return errors.New(
+					fmt.Sprintf("menus.%s[%d] must define either an url or a page in your config.json, not both", menu, i))
+			}
+
+			if len(item.URL) > 0 && len(item.Name) == 0 {
+				return errors.New(fmt.Sprintf("you must define a name for menus.%s[%d] in your config.json", menu, i))
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkConfigThemes(c Config) error {
+
+	for _, root := range c.themeRoots() {
+		theme, err := os.Stat(root)
+		if os.IsNotExist(err) {
+			return errors.New(fmt.Sprintf("you must create the theme folder %s to use theme '%s'", root, filepath.Base(root)))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !theme.IsDir() {
+			return errors.New(fmt.Sprintf("theme %s must be a folder", root))
+		}
+	}
+
+	return nil
+}
+
+func checkConfigOutputFormats(formats []OutputFormat) error {
+
+	for i, format := range formats {
+		if len(format.Name) == 0 {
+			return errors.New(fmt.Sprintf("you must define a name for output_formats[%d] in your config.json", i))
+		}
+
+		if !strings.HasPrefix(format.Extension, ".") || len(format.Extension) < 2 {
+			return errors.New(
+				fmt.Sprintf("output format %s must define an extension starting with a dot in your config.json", format.Name))
+		}
+
+		if len(format.MediaType) == 0 {
+			return errors.New(fmt.Sprintf("you must define a media_type for output format %s in your config.json", format.Name))
+		}
+	}
+
+	return nil
+}
+
+func checkConfigOutputs(c Config) error {
+
+	formats := c.AllOutputFormats()
+	for kind, names := range c.Outputs {
+		for _, name := range names {
+			if _, ok := formats.ByName(name); !ok {
+				return errors.New(fmt.Sprintf("outputs.%s lists the unknown output format %s in your config.json", kind, name))
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkConfigPlugins(plugins []PluginConfig) error {
+
+	seen := map[string]bool{}
+	for i, plugin := range plugins {
+		if len(plugin.Name) == 0 {
+			return errors.New(fmt.Sprintf("you must define a name for plugins[%d] in your config.json", i))
+		}
+
+		if seen[plugin.Name] {
+			return errors.New(fmt.Sprintf("plugin %s is listed twice in the plugins of your config.json", plugin.Name))
+		}
+		seen[plugin.Name] = true
+	}
+
+	return nil
+}
+
+func checkConfigExternalPlugins(plugins []ExternalPluginConfig) error {
+
+	for i, plugin := range plugins {
+		if len(plugin.Command) == 0 {
+			return errors.New(fmt.Sprintf("you must define a command for external_plugins[%d] in your config.json", i))
+		}
+
+		if len(plugin.Timeout) > 0 {
+			if timeout, err := time.ParseDuration(plugin.Timeout); err != nil || timeout <= 0 {
+				return errors.New(
+					fmt.Sprintf("external_plugins[%d] must define a timeout like \"30s\" in your config.json", i))
+			}
+		}
+	}
+
+	return nil
+}
+
 func checkConfigFile(folder string, cfgFile string) error {
 	configFile, err := os.Stat(fmt.Sprintf("%s/%s", folder, cfgFile))
 
@@ -217,6 +428,10 @@ func NewConfigFromFile(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
+func NewDefaultConfig(sitePath string) *Config {
+	return SetDefaultConfigs(&Config{Template: Template{Layout: "layout.html"}}, filepath.ToSlash(filepath.Clean(sitePath)))
+}
+
 func SetDefaultConfigs(config *Config, sitePath string) *Config {
 
 	if len(config.SiteRoot) <= 0 {
@@ -229,6 +444,12 @@ func SetDefaultConfigs(config *Config, sitePath string) *Config {
 		config.Content = fmt.Sprintf("%s/%s", sitePath, config.Content)
 	}
 
+	if len(config.Data) <= 0 {
+		config.Data = fmt.Sprintf("%s/%s", sitePath, "data")
+	} else {
+		config.Data = fmt.Sprintf("%s/%s", sitePath, config.Data)
+	}
+
 	if len(config.Output) <= 0 {
 		config.Output = fmt.Sprintf("%s/%s", sitePath, "output")
 	} else {
@@ -241,17 +462,26 @@ func SetDefaultConfigs(config *Config, sitePath string) *Config {
 		config.Template.Path = fmt.Sprintf("%s/%s", sitePath, config.Template.Path)
 	}
 
-
 	if len(config.Template.Partials) <= 0 {
 		config.Template.Partials = fmt.Sprintf("%s/%s", sitePath, "partials")
 	} else {
 		config.Template.Partials = fmt.Sprintf("%s/%s", sitePath, config.Template.Partials)
 	}
 
+	if len(config.ThemesDir) <= 0 {
+		config.ThemesDir = fmt.Sprintf("%s/%s", sitePath, "themes")
+	} else {
+		config.ThemesDir = fmt.Sprintf("%s/%s", sitePath, config.ThemesDir)
+	}
+
 	if len(config.Template.Layout) <= 0 {
 		config.Template.Layout = "index.html"
 	}
 
+	if config.Params == nil {
+		config.Params = map[string]interface{}{}
+	}
+
 	if len(config.Server.Port) <= 0 {
 		config.Server.Port = "6780"
 	}
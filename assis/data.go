@@ -0,0 +1,123 @@
+package assis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var dataExt = []string{".json", ".yaml", ".yml", ".csv"}
+
+// LoadData reads every data file under root into a nested map keyed by
+// directory and file name, so data/nav/main.json is found at
+// .Site.Data.nav.main. A missing root yields an empty map.
+func LoadData(root string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	exists, err := Exists(root)
+	if err != nil || !exists {
+		return data, err
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isDataFile(path) {
+			return nil
+		}
+
+		value, err := readDataFile(path)
+		if err != nil {
+			return fmt.Errorf("data file %s: %w", filepath.ToSlash(path), err)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(filepath.ToSlash(rel), "/")
+		keys[len(keys)-1] = strings.TrimSuffix(keys[len(keys)-1], filepath.Ext(path))
+
+		node := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func isDataFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range dataExt {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func readDataFile(path string) (interface{}, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, &value)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &value)
+	case ".csv":
+		value, err = readCSV(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// readCSV returns one map per record, keyed by the header row.
+func readCSV(path string) ([]map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []map[string]interface{}{}
+	if len(rows) == 0 {
+		return records, nil
+	}
+	header := rows[0]
+	for _, row := range rows[1:] {
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			record[strings.TrimSpace(column)] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
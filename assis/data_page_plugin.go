@@ -0,0 +1,226 @@
+package assis
+
+import (
+	"fmt"
+	"github.com/gammazero/workerpool"
+	"github.com/gosimple/slug"
+	"go.uber.org/zap"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var permalinkToken = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+type DataPagePlugin struct {
+	config *Config
+	pages  map[string][]*Page
+	lists  map[string]*Page
+	logger *zap.Logger
+}
+
+func NewDataPagePlugin(config *Config, logger *zap.Logger) DataPagePlugin {
+	return DataPagePlugin{
+		config: config,
+		pages:  map[string][]*Page{},
+		lists:  map[string]*Page{},
+		logger: logger,
+	}
+}
+
+func (d DataPagePlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "data_pages", Version: builtinVersion, After: []string{"articles"}}
+}
+
+func (d DataPagePlugin) OnRegisterCustomFunction() map[string]interface{} {
+	return map[string]interface{}{
+		"dataPages": d.dataPages,
+	}
+}
+
+func (d DataPagePlugin) dataPages(name string) []*Page {
+	if pages, ok := d.pages[name]; ok {
+		return pages
+	}
+	return []*Page{}
+}
+
+// OnBuildSite adds a page for every record, and the list pages, to the site
+// graph.
+func (d DataPagePlugin) OnBuildSite(site *Site) error {
+	for _, source := range d.config.DataPages {
+		pages, err := d.buildPages(source, site)
+		if err != nil {
+			return err
+		}
+		d.pages[source.Name] = pages
+		for _, page := range pages {
+			site.AddPage(page)
+		}
+
+		if source.List != nil {
+			d.lists[source.Name] = d.buildList(source, pages)
+			site.AddPage(d.lists[source.Name])
+		}
+	}
+	return nil
+}
+
+func (d DataPagePlugin) OnRender(t AssisTemplate, site *Site, siteFiles SiteFiles, templates Templates) error {
+	d.logger.Info("Start data pages rendering")
+	var errs errorCollector
+	wp := workerpool.New(2)
+	for _, source := range d.config.DataPages {
+		source := source
+		wp.Submit(func() {
+			errs.add(d.renderSource(source, t, templates))
+		})
+	}
+	wp.StopWait()
+	d.logger.Info("Finished data pages rendering")
+	return errs.err()
+}
+
+func (d DataPagePlugin) buildPages(source DataPage, site *Site) ([]*Page, error) {
+	records, err := dataRecords(site.Data, source.Source)
+	if err != nil {
+		return nil, fmt.Errorf("data page %s: %w", source.Name, err)
+	}
+
+	titleField := source.TitleField
+	if titleField == "" {
+		titleField = "title"
+	}
+
+	seen := map[string]bool{}
+	pages := make([]*Page, 0, len(records))
+	for i, record := range records {
+		permalink, err := expandPermalink(source.Permalink, record)
+		if err != nil {
+			return nil, fmt.Errorf("data page %s, record %d: %w", source.Name, i, err)
+		}
+		if seen[permalink] {
+			return nil, fmt.Errorf("data page %s, record %d: duplicated permalink %s", source.Name, i, permalink)
+		}
+		seen[permalink] = true
+
+		// Records without a title are titled after their page, like content
+		// files without one.
+		id := strings.TrimSuffix(path.Base(permalink), path.Ext(permalink))
+		title := humanize(id)
+		if value, ok := record[titleField]; ok && value != nil {
+			title = fmt.Sprint(value)
+		}
+
+		pages = append(pages, &Page{
+			Kind:        KindData,
+			ID:          id,
+			Source:      source.Source,
+			Output:      fmt.Sprintf("%s/%s", d.config.Output, permalink),
+			Permalink:   permalink,
+			SectionPath: sectionPath(path.Dir(permalink)),
+			Title:       title,
+			Layout:      source.Template,
+			Params:      record,
+		})
+	}
+	return pages, nil
+}
+
+func (d DataPagePlugin) buildList(source DataPage, pages []*Page) *Page {
+	permalink := strings.TrimPrefix(source.List.Permalink, "/")
+	if path.Ext(permalink) == "" {
+		permalink = path.Join(permalink, "index.html")
+	}
+	return &Page{
+		Kind:        KindList,
+		ID:          source.Name,
+		Source:      source.Source,
+		Output:      fmt.Sprintf("%s/%s", d.config.Output, permalink),
+		Permalink:   permalink,
+		SectionPath: sectionPath(path.Dir(permalink)),
+		Title:       source.List.Title,
+		Layout:      source.List.Template,
+		Params:      map[string]interface{}{},
+		Pages:       pages,
+	}
+}
+
+func (d DataPagePlugin) renderSource(source DataPage, t AssisTemplate, templates Templates) error {
+	pages := append([]*Page{}, d.pages[source.Name]...)
+	if list, ok := d.lists[source.Name]; ok {
+		pages = append(pages, list)
+	}
+
+	for _, page := range pages {
+		for _, output := range page.Outputs() {
+			templateFile, err := templates.Lookup(output)
+			if err != nil {
+				return err
+			}
+			if err := t.RenderLayout(templates, templateFile, output.Output, output); err != nil {
+				return err
+			}
+			d.logger.Info("Rendered data page to: " + output.Output)
+		}
+	}
+	return nil
+}
+
+// dataRecords finds the records of a data source given as a path inside the
+// data folder, with or without extension: "catalog/products.csv".
+func dataRecords(data map[string]interface{}, source string) ([]map[string]interface{}, error) {
+	keys := strings.Split(strings.Trim(filepath.ToSlash(source), "/"), "/")
+	keys[len(keys)-1] = strings.TrimSuffix(keys[len(keys)-1], path.Ext(keys[len(keys)-1]))
+
+	var node interface{} = data
+	for _, key := range keys {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("data source %s not found", source)
+		}
+		if node, ok = m[key]; !ok {
+			return nil, fmt.Errorf("data source %s not found", source)
+		}
+	}
+
+	switch list := node.(type) {
+	case []map[string]interface{}:
+		return list, nil
+	case []interface{}:
+		records := make([]map[string]interface{}, 0, len(list))
+		for i, item := range list {
+			record, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("data source %s: record %d is not an object", source, i)
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("data source %s is not a list of records", source)
+}
+
+// expandPermalink replaces each :field token of pattern with the slug of the
+// record field, e.g. "/products/:category/:name" -> "products/shoes/runner.html".
+func expandPermalink(pattern string, record map[string]interface{}) (string, error) {
+	var missing []string
+	out := permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		value, ok := record[token[1:]]
+		if !ok || value == nil {
+			missing = append(missing, token[1:])
+			return ""
+		}
+		return slug.Make(fmt.Sprint(value))
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("permalink %s: missing fields %s", pattern, strings.Join(missing, ", "))
+	}
+
+	out = strings.TrimPrefix(path.Clean("/"+out), "/")
+	if path.Ext(out) == "" {
+		out += ".html"
+	}
+	return out, nil
+}
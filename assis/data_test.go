@@ -0,0 +1,38 @@
+package assis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadData(t *testing.T) {
+	t.Run("nested by path and filename", func(t *testing.T) {
+		data, err := LoadData("./mock/_site/data")
+		assert.NoError(t, err)
+
+		nav := data["nav"].(map[string]interface{})
+		assert.Len(t, nav["main"], 2)
+		assert.Len(t, data["team"], 2)
+
+		pricing := data["pricing"].([]map[string]interface{})
+		assert.Equal(t, "pro", pricing[1]["plan"])
+		assert.Equal(t, "25", pricing[1]["price"])
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		data, err := LoadData("./mock/_site/nodata")
+		assert.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("parse error names the file", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644))
+
+		_, err := LoadData(dir)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "broken.json")
+	})
+}
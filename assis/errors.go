@@ -0,0 +1,131 @@
+package assis
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TemplateError is a template that failed to parse or execute while rendering
+// a page. It tells the content file being rendered, the template file and
+// line at fault, when the error names them, and shows the lines around it.
+type TemplateError struct {
+	Content  string
+	Template string
+	Line     int
+	Excerpt  string
+	Err      error
+}
+
+func (e *TemplateError) Error() string {
+	msg := e.Content + ": " + e.Err.Error()
+	if e.Template != "" && e.Template != e.Content {
+		msg = fmt.Sprintf("%s: in %s:%d: %s", e.Content, e.Template, e.Line, e.Err.Error())
+	}
+	if e.Excerpt != "" {
+		msg += "\n" + e.Excerpt
+	}
+	return msg
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateLocation matches the template name and line Go puts in template
+// errors, e.g. "template: site/template/single.html:12:3: executing ...".
+// Templates are named after their slash path, see templateName.
+var templateLocation = regexp.MustCompile(`template: ?([^:\s]+):(\d+)`)
+
+// newTemplateError locates err in files, the template files and content file
+// a page was rendered with, by the name each was parsed under.
+func newTemplateError(content string, files []string, err error) error {
+	var templateError *TemplateError
+	if err == nil || errors.As(err, &templateError) {
+		return err
+	}
+
+	out := &TemplateError{Content: content, Err: err}
+	if match := templateLocation.FindStringSubmatch(err.Error()); match != nil {
+		out.Line, _ = strconv.Atoi(match[2])
+		for _, file := range files {
+			if file != "" && templateName(file) == match[1] {
+				out.Template = templateName(file)
+				break
+			}
+		}
+		if out.Template != "" {
+			out.Excerpt = excerpt(out.Template, out.Line)
+		}
+	}
+	return out
+}
+
+// excerpt shows the line of a file and the ones around it, numbered.
+func excerpt(file string, line int) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil || line <= 0 {
+		return ""
+	}
+
+	lines := strings.Split(string(b), "\n")
+	var out []string
+	for i := line - 1; i <= line+1; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		marker := "  "
+		if i == line {
+			marker = "> "
+		}
+		out = append(out, fmt.Sprintf("%s%4d | %s", marker, i, lines[i-1]))
+	}
+	return strings.Join(out, "\n")
+}
+
+// RenderErrors holds every error of a build step, so one failing page doesn't
+// hide the others.
+type RenderErrors []error
+
+func (r RenderErrors) Error() string {
+	if len(r) == 1 {
+		return r[0].Error()
+	}
+	msgs := make([]string, len(r))
+	for i, err := range r {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors:\n%s", len(r), strings.Join(msgs, "\n"))
+}
+
+// errorCollector gathers the errors of the jobs of a worker pool.
+type errorCollector struct {
+	mu   sync.Mutex
+	errs RenderErrors
+}
+
+func (c *errorCollector) add(err error) {
+	if err == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if errs, ok := err.(RenderErrors); ok {
+		c.errs = append(c.errs, errs...)
+		return
+	}
+	c.errs = append(c.errs, err)
+}
+
+func (c *errorCollector) err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
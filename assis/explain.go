@@ -0,0 +1,182 @@
+package assis
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Explanation tells how a page is rendered: the template names looked up
+// for it, Resolved being the one found, the files parsed, in order, with the
+// role each one plays, where every defined template comes from and the data
+// the entry template gets.
+type Explanation struct {
+	Page       *Page
+	Candidates []string
+	Resolved   string
+	Template   string
+	Files      []ExplainedFile
+	Blocks     []ExplainedBlock
+	Entry      string
+	Data       string
+}
+
+// ExplainedFile is one file parsed for a page: its role, one of base,
+// partial, template or content, and the templates it defines.
+type ExplainedFile struct {
+	File    string
+	Role    string
+	Defines []string
+}
+
+// ExplainedBlock is a defined template, with the file whose definition is
+// used and the files defining it before, whose definitions it overrides.
+type ExplainedBlock struct {
+	Name       string
+	File       string
+	Overridden []string
+}
+
+// Page finds the page of a content file, given as a path relative to the
+// working directory or absolute, once LoadFilesAsync built the site.
+func (a *Assis) Page(source string) (*Page, error) {
+	source = absPath(source)
+	for _, page := range a.site.Pages {
+		if absPath(page.Source) == source {
+			return page, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a page of the site", source)
+}
+
+// parseLog keeps, for each output rendered by RenderPage, what was parsed to
+// render it.
+type parseLog struct {
+	mu      sync.Mutex
+	outputs map[string]parseRecord
+}
+
+// parseRecord is what was parsed for one output: the files, in order, and the
+// template executed.
+type parseRecord struct {
+	files []ExplainedFile
+	entry string
+}
+
+func newParseLog() *parseLog {
+	return &parseLog{outputs: map[string]parseRecord{}}
+}
+
+func (l *parseLog) add(output string, record parseRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.outputs[output] = record
+}
+
+func (l *parseLog) get(output string) (parseRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.outputs[output]
+	return record, ok
+}
+
+// Explain tells how a page was rendered, from what RenderPage parsed for it.
+// The page is explained even when executing its templates failed.
+func (a *Assis) Explain(page *Page) (*Explanation, error) {
+	var record parseRecord
+	ok := false
+	if a.parsed != nil {
+		record, ok = a.parsed.get(page.Output)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s was not parsed, render it with RenderPage first", page.Output)
+	}
+
+	templateFile, err := a.templates.Lookup(page)
+	if err != nil {
+		return nil, err
+	}
+	layoutNames, names := a.templates.lookupNames(page)
+
+	out := &Explanation{
+		Page:       page,
+		Candidates: append(layoutNames, names...),
+		Template:   templateFile,
+		Files:      record.files,
+		Entry:      record.entry,
+	}
+	for _, name := range out.Candidates {
+		if file, ok := a.templates.resolve(name); ok && file == templateFile {
+			out.Resolved = name
+			break
+		}
+	}
+
+	blocks := map[string]*ExplainedBlock{}
+	var order []string
+	for _, file := range out.Files {
+		for _, name := range file.Defines {
+			block, ok := blocks[name]
+			if !ok {
+				block = &ExplainedBlock{Name: name}
+				blocks[name] = block
+				order = append(order, name)
+			}
+			if block.File != "" {
+				block.Overridden = append(block.Overridden, block.File)
+			}
+			block.File = file.File
+		}
+	}
+	for _, name := range order {
+		out.Blocks = append(out.Blocks, *blocks[name])
+	}
+
+	var data interface{} = page
+	if page.Kind == KindArticle {
+		data = newArticle(page)
+	}
+	out.Data = NewFunctionsPlugin().debug(data)
+	return out, nil
+}
+
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "page: %s (%s, %s)\n", e.Page.Source, e.Page.Kind, e.Page.Format.Name)
+	fmt.Fprintf(&b, "output: %s\n", e.Page.Output)
+
+	b.WriteString("\ntemplate lookup:\n")
+	for _, name := range e.Candidates {
+		marker := "  "
+		if name == e.Resolved {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "%s%s\n", marker, name)
+	}
+	if e.Template == "" {
+		b.WriteString("  no template found\n")
+	} else {
+		fmt.Fprintf(&b, "  resolved to %s\n", e.Template)
+	}
+
+	b.WriteString("\nfiles, in parse order:\n")
+	for i, file := range e.Files {
+		fmt.Fprintf(&b, "  %d. %-8s %s", i+1, file.Role, file.File)
+		if len(file.Defines) > 0 {
+			fmt.Fprintf(&b, " (defines %s)", strings.Join(file.Defines, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\ndefined templates:\n")
+	for _, block := range e.Blocks {
+		fmt.Fprintf(&b, "  %s from %s", block.Name, block.File)
+		if len(block.Overridden) > 0 {
+			fmt.Fprintf(&b, ", overriding %s", strings.Join(block.Overridden, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\ndata passed to %s:\n%s\n", e.Entry, e.Data)
+	return b.String()
+}
@@ -0,0 +1,403 @@
+package assis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luizfsnunes/assis/sdk"
+	"go.uber.org/zap"
+)
+
+// defaultExternalPluginTimeout bounds each call to an external plugin whose
+// config sets no timeout.
+const defaultExternalPluginTimeout = 30 * time.Second
+
+// ExternalPluginConfig is a plugin running as its own program, spoken to over
+// the protocol of the sdk package. Relative commands containing a slash are
+// found from the site root, others in the PATH. Timeout bounds each call, e.g.
+// "10s"; a plugin not answering in time is killed and fails the build.
+// Options are handed to the plugin as is.
+type ExternalPluginConfig struct {
+	Name    string                 `json:"name"`
+	Command string                 `json:"command"`
+	Args    []string               `json:"args"`
+	Env     map[string]string      `json:"env"`
+	Timeout string                 `json:"timeout"`
+	Options map[string]interface{} `json:"options"`
+}
+
+func (e ExternalPluginConfig) timeout() time.Duration {
+	if e.Timeout == "" {
+		return defaultExternalPluginTimeout
+	}
+	timeout, _ := time.ParseDuration(e.Timeout)
+	return timeout
+}
+
+// ExternalPlugin is a launched external plugin. It implements the hooks
+// matching the methods of the protocol and only calls the plugin for the ones
+// it declared. Calls are made one at a time, each one waiting for its answer.
+type ExternalPlugin struct {
+	name    string
+	version string
+	hooks   map[string]bool
+	timeout time.Duration
+	logger  *zap.Logger
+
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan sdk.Message
+	done      chan struct{}
+	waitErr   error
+
+	mu     sync.Mutex
+	nextID int64
+	closed bool
+}
+
+// ExternalPlugins are the external plugins of a build, to close once it is
+// done.
+type ExternalPlugins []*ExternalPlugin
+
+// LaunchExternalPlugins starts the external plugins of the config the plugins
+// section doesn't turn off and initializes them. On failure the ones already
+// started are closed.
+func LaunchExternalPlugins(config *Config, logger *zap.Logger) (ExternalPlugins, error) {
+	var plugins ExternalPlugins
+	for _, pluginConfig := range config.ExternalPlugins {
+		if pluginConfig.Name != "" && config.pluginDisabled(pluginConfig.Name) {
+			continue
+		}
+		plugin, err := launchExternalPlugin(config, pluginConfig, logger)
+		if err != nil {
+			plugins.Close()
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// Plugins returns the plugins to hand to NewAssis.
+func (e ExternalPlugins) Plugins() []interface{} {
+	plugins := make([]interface{}, len(e))
+	for i, plugin := range e {
+		plugins[i] = plugin
+	}
+	return plugins
+}
+
+// Close shuts every plugin down.
+func (e ExternalPlugins) Close() error {
+	var errs RenderErrors
+	for _, plugin := range e {
+		if err := plugin.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func launchExternalPlugin(config *Config, pluginConfig ExternalPluginConfig, logger *zap.Logger) (*ExternalPlugin, error) {
+	command := pluginConfig.Command
+	if !filepath.IsAbs(command) && strings.Contains(command, "/") {
+		command = filepath.Join(config.SiteRoot, command)
+	}
+	name := pluginConfig.Name
+	if name == "" {
+		name = filepath.Base(command)
+	}
+
+	cmd := exec.Command(command, pluginConfig.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range pluginConfig.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	p := &ExternalPlugin{
+		name:      name,
+		timeout:   pluginConfig.timeout(),
+		logger:    logger.With(zap.String("plugin", name)),
+		cmd:       cmd,
+		responses: make(chan sdk.Message, 1),
+		done:      make(chan struct{}),
+	}
+	cmd.Stderr = &lineWriter{log: func(line string) { p.logger.Warn(line) }}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	p.stdin = stdin
+	go p.read(stdout)
+
+	var result sdk.InitializeResult
+	err = p.call(sdk.MethodInitialize, sdk.InitializeParams{
+		ProtocolVersion: sdk.ProtocolVersion,
+		Site: sdk.Site{
+			Title:    config.Title,
+			BaseURL:  config.BaseURL,
+			Language: config.Language,
+			Params:   config.Params,
+		},
+		Options: pluginConfig.Options,
+	}, &result)
+	if err == nil && result.ProtocolVersion != sdk.ProtocolVersion {
+		err = fmt.Errorf("plugin %s speaks protocol version %d, assis speaks %d", name, result.ProtocolVersion, sdk.ProtocolVersion)
+	}
+	if err != nil {
+		p.kill()
+		return nil, err
+	}
+
+	if pluginConfig.Name == "" && result.Name != "" {
+		p.name = result.Name
+	}
+	p.version = result.Version
+	p.hooks = map[string]bool{}
+	for _, hook := range result.Hooks {
+		p.hooks[hook] = true
+	}
+	p.logger.Info(fmt.Sprintf("Launched plugin %s %s", p.name, p.version))
+	return p, nil
+}
+
+// read routes the responses of the plugin to the pending call and logs the
+// rest of its output, until it exits.
+func (p *ExternalPlugin) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var msg sdk.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.JSONRPC != "2.0" {
+			p.logger.Info(scanner.Text())
+			continue
+		}
+
+		switch {
+		case msg.ID != nil:
+			select {
+			case p.responses <- msg:
+			default:
+				p.logger.Warn(fmt.Sprintf("Unexpected response %d", *msg.ID))
+			}
+		case msg.Method == sdk.MethodLog:
+			var params sdk.LogParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				p.logger.Warn(fmt.Sprintf("Invalid log notification: %s", err))
+				continue
+			}
+			p.log(params)
+		case msg.Error != nil:
+			p.logger.Error(msg.Error.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		p.logger.Error(err.Error())
+	}
+	p.waitErr = p.cmd.Wait()
+	close(p.done)
+}
+
+func (p *ExternalPlugin) log(params sdk.LogParams) {
+	switch params.Level {
+	case "debug":
+		p.logger.Debug(params.Message)
+	case "warn":
+		p.logger.Warn(params.Message)
+	case "error":
+		p.logger.Error(params.Message)
+	default:
+		p.logger.Info(params.Message)
+	}
+}
+
+// call sends a request and decodes the result into result, if not nil. A
+// plugin not answering within the timeout is killed.
+func (p *ExternalPlugin) call(method string, params interface{}, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("plugin %s is closed", p.name)
+	}
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %s: %w", p.name, method, err)
+	}
+	p.nextID++
+	id := p.nextID
+	b, err := json.Marshal(sdk.Message{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	if _, err := p.stdin.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("plugin %s: %s: %w", p.name, method, err)
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case res := <-p.responses:
+			if *res.ID != id {
+				p.logger.Warn(fmt.Sprintf("Unexpected response %d", *res.ID))
+				continue
+			}
+			if res.Error != nil {
+				return fmt.Errorf("plugin %s: %s: %w", p.name, method, res.Error)
+			}
+			if result == nil || len(res.Result) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(res.Result, result); err != nil {
+				return fmt.Errorf("plugin %s: %s: %w", p.name, method, err)
+			}
+			return nil
+		case <-p.done:
+			p.closed = true
+			return fmt.Errorf("plugin %s exited during %s: %v", p.name, method, p.waitErr)
+		case <-timer.C:
+			p.closed = true
+			p.kill()
+			return fmt.Errorf("plugin %s: %s timed out after %s", p.name, method, p.timeout)
+		}
+	}
+}
+
+func (p *ExternalPlugin) kill() {
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	<-p.done
+}
+
+// Close asks the plugin to shut down and waits for it to exit, killing it if
+// it doesn't within the timeout. Plugins already killed are left alone.
+func (p *ExternalPlugin) Close() error {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil
+	}
+	err := p.call(sdk.MethodShutdown, struct{}{}, nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return err
+	}
+	p.closed = true
+	p.stdin.Close()
+	select {
+	case <-p.done:
+	case <-time.After(p.timeout):
+		p.kill()
+	}
+	return err
+}
+
+func (p *ExternalPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: p.name, Version: p.version}
+}
+
+func (p *ExternalPlugin) AfterLoadFiles(site *Site, files SiteFiles) error {
+	if !p.hooks[sdk.MethodLoadFiles] {
+		return nil
+	}
+	var sources []string
+	for _, container := range files {
+		for _, file := range container.files {
+			sources = append(sources, filepath.ToSlash(container.FullFilename(file)))
+		}
+	}
+	sort.Strings(sources)
+	return p.call(sdk.MethodLoadFiles, sdk.LoadFilesParams{Files: sources}, nil)
+}
+
+// OnBuildSite keeps the data of the plugin in the site data, under its name.
+func (p *ExternalPlugin) OnBuildSite(site *Site) error {
+	if !p.hooks[sdk.MethodData] {
+		return nil
+	}
+	var result sdk.DataResult
+	if err := p.call(sdk.MethodData, struct{}{}, &result); err != nil {
+		return err
+	}
+	site.Data[p.name] = result.Data
+	return nil
+}
+
+// OnPageRendered has the plugin transform one output. The render workers
+// rendering pages at the same time wait on each other here, since calls are
+// made one at a time: a plugin implementing transform_page renders the site
+// at the pace it answers.
+func (p *ExternalPlugin) OnPageRendered(ctx *BuildContext, page *Page, output string, b []byte) ([]byte, error) {
+	if !p.hooks[sdk.MethodTransformPage] {
+		return b, nil
+	}
+	params := sdk.TransformPageParams{Page: sdk.Page{Output: output, Content: string(b)}}
+	if page != nil {
+		params.Page.Source = page.Source
+		params.Page.Permalink = page.Permalink
+		params.Page.Kind = page.Kind
+		params.Page.Title = page.Title
+		params.Page.Format = page.Format.Name
+		params.Page.Params = page.Params
+	}
+	var result sdk.TransformPageResult
+	if err := p.call(sdk.MethodTransformPage, params, &result); err != nil {
+		return nil, err
+	}
+	return []byte(result.Content), nil
+}
+
+func (p *ExternalPlugin) AfterGeneratedFiles(site *Site, files []string) error {
+	if !p.hooks[sdk.MethodAfterGenerate] {
+		return nil
+	}
+	return p.call(sdk.MethodAfterGenerate, sdk.AfterGenerateParams{Files: files}, nil)
+}
+
+// lineWriter logs what is written to it, one line at a time.
+type lineWriter struct {
+	log func(string)
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(b)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		line := strings.TrimRight(string(w.buf.Next(i+1)), "\r\n")
+		if line != "" {
+			w.log(line)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package assis
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luizfsnunes/assis/sdk"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestHelperExternalPlugin is the external plugin the tests launch: the test
+// binary run again with ASSIS_HELPER_PLUGIN telling how to behave.
+func TestHelperExternalPlugin(t *testing.T) {
+	mode := os.Getenv("ASSIS_HELPER_PLUGIN")
+	if mode == "" {
+		return
+	}
+
+	suffix := ""
+	plugin := &sdk.Plugin{
+		Name:    "helper",
+		Version: "0.1.0",
+		Initialize: func(params sdk.InitializeParams) error {
+			suffix, _ = params.Options["suffix"].(string)
+			return nil
+		},
+		Data: func() (map[string]interface{}, error) {
+			if mode == "slow" {
+				time.Sleep(10 * time.Second)
+			}
+			return map[string]interface{}{"answer": 42}, nil
+		},
+		TransformPage: func(page sdk.Page) (string, error) {
+			if mode == "error" {
+				return "", errors.New("broken page")
+			}
+			return page.Content + suffix, nil
+		},
+	}
+	os.Stderr.WriteString("helper started\n")
+	if err := plugin.Serve(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func helperPlugin(mode string) ExternalPluginConfig {
+	return ExternalPluginConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestHelperExternalPlugin"},
+		Env:     map[string]string{"ASSIS_HELPER_PLUGIN": mode},
+		Options: map[string]interface{}{"suffix": "<!-- external -->"},
+	}
+}
+
+func TestExternalPlugin(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("run the hooks it declares", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.ExternalPlugins = []ExternalPluginConfig{helperPlugin("ok")}
+		external, err := LaunchExternalPlugins(cfg, logger)
+		assert.NoError(t, err)
+		assert.Equal(t, PluginInfo{Name: "helper", Version: "0.1.0"}, external[0].Describe())
+
+		plugins := append([]interface{}{NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)}, external.Plugins()...)
+		site := NewAssis(cfg, plugins, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+		assert.NoError(t, site.Generate())
+		assert.Equal(t, map[string]interface{}{"answer": float64(42)}, site.site.Data["helper"])
+		assert.NoError(t, external.Close())
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(b), "<!-- external -->"))
+
+		assert.EqualError(t, external[0].OnBuildSite(site.site), "plugin helper is closed")
+	})
+
+	t.Run("fail the build with its errors", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.ExternalPlugins = []ExternalPluginConfig{helperPlugin("error")}
+		external, err := LaunchExternalPlugins(cfg, logger)
+		assert.NoError(t, err)
+		defer external.Close()
+
+		plugins := append([]interface{}{NewHTMLPlugin(cfg, logger)}, external.Plugins()...)
+		site := NewAssis(cfg, plugins, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+		err = site.Generate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "plugin helper: transform_page: broken page")
+	})
+
+	t.Run("kill plugins running out of time", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		slow := helperPlugin("slow")
+		slow.Timeout = "200ms"
+		cfg.ExternalPlugins = []ExternalPluginConfig{slow}
+		external, err := LaunchExternalPlugins(cfg, logger)
+		assert.NoError(t, err)
+
+		start := time.Now()
+		site := NewAssis(cfg, external.Plugins(), logger)
+		assert.EqualError(t, site.LoadFilesAsync(), "plugin helper: data timed out after 200ms")
+		assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+		assert.NoError(t, external.Close())
+	})
+
+	t.Run("report commands that can't start", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.ExternalPlugins = []ExternalPluginConfig{helperPlugin("ok"), {Command: "./plugins/missing"}}
+		_, err := LaunchExternalPlugins(cfg, logger)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "plugin missing: ")
+	})
+
+	t.Run("validate the config", func(t *testing.T) {
+		assert.EqualError(t, checkConfigExternalPlugins([]ExternalPluginConfig{{Name: "a"}}),
+			"you must define a command for external_plugins[0] in your config.json")
+		assert.EqualError(t, checkConfigExternalPlugins([]ExternalPluginConfig{{Command: "a", Timeout: "soon"}}),
+			"external_plugins[0] must define a timeout like \"30s\" in your config.json")
+		assert.NoError(t, checkConfigExternalPlugins([]ExternalPluginConfig{{Command: "a", Timeout: "5s"}}))
+	})
+}
@@ -0,0 +1,154 @@
+package assis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const frontMatterDelimiter = "---"
+
+// splitFrontMatter separates a leading YAML block fenced by "---" lines from
+// the rest of the file. Keys are lower cased, like article headers. Files
+// without front matter are returned unchanged. The number of lines taken by
+// the block is returned so callers can keep line numbers of the body aligned
+// with the source file.
+func splitFrontMatter(b []byte) (map[string]interface{}, []byte, int, error) {
+	params := map[string]interface{}{}
+
+	first := firstLine(b)
+	if strings.TrimSpace(string(first)) != frontMatterDelimiter {
+		return params, b, 0, nil
+	}
+
+	rest := b[len(first):]
+	lines := 1
+	var block []byte
+	for len(rest) > 0 {
+		line := firstLine(rest)
+		rest = rest[len(line):]
+		lines++
+		if strings.TrimSpace(string(line)) == frontMatterDelimiter {
+			var raw map[string]interface{}
+			if err := yaml.Unmarshal(block, &raw); err != nil {
+				return nil, nil, 0, fmt.Errorf("front matter: %w", err)
+			}
+			for key, value := range raw {
+				params[strings.ToLower(key)] = normalizeParam(value)
+			}
+			return params, rest, lines, nil
+		}
+		block = append(block, line...)
+	}
+	return nil, nil, 0, fmt.Errorf("front matter: missing closing %s", frontMatterDelimiter)
+}
+
+// splitHeaders reads the "key: value" header block markdown articles start
+// with, ended by a blank line. A fenced YAML block is accepted as well.
+func splitHeaders(b []byte) (map[string]interface{}, []byte, int, error) {
+	if strings.TrimSpace(string(firstLine(b))) == frontMatterDelimiter {
+		return splitFrontMatter(b)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("headers: %w", err)
+	}
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	params := map[string]interface{}{}
+	for key := range msg.Header {
+		params[strings.ToLower(key)] = msg.Header.Get(key)
+	}
+	return params, body, countLines(b[:len(b)-len(body)]), nil
+}
+
+func firstLine(b []byte) []byte {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i+1]
+	}
+	return b
+}
+
+func countLines(b []byte) int {
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+// normalizeParam keeps YAML dates in the same text form article headers use.
+func normalizeParam(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeParam(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeParam(item)
+		}
+	}
+	return value
+}
+
+// stringParam reads a front matter value as a string, "" when missing.
+func stringParam(params map[string]interface{}, key string) string {
+	value, ok := params[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// boolParam reads a front matter value written either as a YAML boolean or
+// as the text "true"/"false".
+func boolParam(params map[string]interface{}, key string, fallback bool) bool {
+	switch value := params[key].(type) {
+	case bool:
+		return value
+	case string:
+		switch strings.TrimSpace(value) {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+	}
+	return fallback
+}
+
+// listParam reads a front matter value written either as a YAML list or as
+// comma separated text.
+func listParam(params map[string]interface{}, key string) []string {
+	var out []string
+	switch value := params[key].(type) {
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			out = append(out, strings.TrimSpace(item))
+		}
+	case []interface{}:
+		for _, item := range value {
+			out = append(out, strings.TrimSpace(fmt.Sprint(item)))
+		}
+	}
+	return out
+}
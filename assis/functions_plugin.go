@@ -0,0 +1,339 @@
+package assis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gomarkdown/markdown"
+	"github.com/gosimple/slug"
+	"html/template"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FunctionsPlugin is the library of generic template functions every site
+// gets: strings, numbers and lists, rendering, encoding and a few helpers.
+// NewGenerator registers it before the other plugins, which can override any
+// of its functions. As with the collection functions, the value a function
+// works on comes last so it can end a pipeline.
+type FunctionsPlugin struct{}
+
+func NewFunctionsPlugin() FunctionsPlugin {
+	return FunctionsPlugin{}
+}
+
+// Describe registers the functions first, so other plugins can override them.
+func (f FunctionsPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "functions", Version: builtinVersion, Priority: map[string]int{HookCustomFunction: -100}}
+}
+
+func (f FunctionsPlugin) OnRegisterCustomFunction() map[string]interface{} {
+	return map[string]interface{}{
+		"lower":        f.lower,
+		"upper":        f.upper,
+		"replace":      f.replace,
+		"split":        f.split,
+		"trim":         f.trim,
+		"add":          f.add,
+		"sub":          f.sub,
+		"mul":          f.mul,
+		"div":          f.div,
+		"mod":          f.mod,
+		"seq":          f.seq,
+		"dict":         f.dict,
+		"list":         f.list,
+		"append":       f.append,
+		"safeHTML":     f.safeHTML,
+		"safeURL":      f.safeURL,
+		"markdownify":  f.markdownify,
+		"plainify":     f.plainify,
+		"jsonify":      f.jsonify,
+		"debug":        f.debug,
+		"urlize":       f.urlize,
+		"base64Encode": f.base64Encode,
+		"base64Decode": f.base64Decode,
+		"now":          time.Now,
+		"default":      f.defaultValue,
+		"cond":         f.cond,
+	}
+}
+
+func (f FunctionsPlugin) lower(s interface{}) string {
+	return strings.ToLower(text(s))
+}
+
+func (f FunctionsPlugin) upper(s interface{}) string {
+	return strings.ToUpper(text(s))
+}
+
+// replace swaps every old for new: replace "old" "new" s.
+func (f FunctionsPlugin) replace(old, new string, s interface{}) string {
+	return strings.ReplaceAll(text(s), old, new)
+}
+
+// split cuts s around sep: split "," s.
+func (f FunctionsPlugin) split(sep string, s interface{}) []string {
+	return strings.Split(text(s), sep)
+}
+
+// trim removes leading and trailing spaces, or the characters of a cutset
+// given first: trim s, trim "/" s.
+func (f FunctionsPlugin) trim(args ...interface{}) (string, error) {
+	switch len(args) {
+	case 1:
+		return strings.TrimSpace(text(args[0])), nil
+	case 2:
+		return strings.Trim(text(args[1]), text(args[0])), nil
+	}
+	return "", errors.New("trim: expected a string and an optional cutset")
+}
+
+func (f FunctionsPlugin) add(a, b interface{}) (interface{}, error) {
+	return arithmetic("add", a, b)
+}
+
+func (f FunctionsPlugin) sub(a, b interface{}) (interface{}, error) {
+	return arithmetic("sub", a, b)
+}
+
+func (f FunctionsPlugin) mul(a, b interface{}) (interface{}, error) {
+	return arithmetic("mul", a, b)
+}
+
+func (f FunctionsPlugin) div(a, b interface{}) (interface{}, error) {
+	return arithmetic("div", a, b)
+}
+
+func (f FunctionsPlugin) mod(a, b interface{}) (interface{}, error) {
+	return arithmetic("mod", a, b)
+}
+
+// arithmetic applies op to two numbers, or numeric strings. The result is an
+// int when both operands are whole numbers, except for a division with a
+// remainder.
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	x, ok := toFloat(a)
+	if !ok {
+		return nil, fmt.Errorf("%s: %v is not a number", op, a)
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return nil, fmt.Errorf("%s: %v is not a number", op, b)
+	}
+
+	var out float64
+	switch op {
+	case "add":
+		out = x + y
+	case "sub":
+		out = x - y
+	case "mul":
+		out = x * y
+	case "div", "mod":
+		if y == 0 {
+			return nil, fmt.Errorf("%s: division by zero", op)
+		}
+		if op == "div" {
+			out = x / y
+		} else {
+			out = math.Mod(x, y)
+		}
+	}
+
+	if x == math.Trunc(x) && y == math.Trunc(y) && out == math.Trunc(out) {
+		return int(out), nil
+	}
+	return out, nil
+}
+
+// seq builds a list of ints: seq 3 is 1 2 3, seq 2 4 is 2 3 4 and seq 0 5 20
+// is 0 5 10 15 20. seq 0 is empty; only seq 4 2 counts down.
+func (f FunctionsPlugin) seq(args ...int) ([]int, error) {
+	first, step, last := 1, 1, 0
+	switch len(args) {
+	case 1:
+		last = args[0]
+	case 2:
+		first, last = args[0], args[1]
+	case 3:
+		first, step, last = args[0], args[1], args[2]
+	default:
+		return nil, errors.New("seq: expected last, first last or first step last")
+	}
+	if step == 0 {
+		return nil, errors.New("seq: step can't be zero")
+	}
+	if first > last && len(args) == 2 {
+		step = -1
+	}
+
+	out := []int{}
+	for i := first; (step > 0 && i <= last) || (step < 0 && i >= last); i += step {
+		out = append(out, i)
+		if len(out) > 10000 {
+			return nil, errors.New("seq: more than 10000 items")
+		}
+	}
+	return out, nil
+}
+
+// dict builds a map from key and value pairs: dict "title" .Title "page" .
+func (f FunctionsPlugin) dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: expected key and value pairs")
+	}
+	out := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := toString(pairs[i])
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		out[key] = pairs[i+1]
+	}
+	return out, nil
+}
+
+// list builds a list of its arguments: list "a" "b". It leaves the builtin
+// slice, which takes a part of a string or a list, alone.
+func (f FunctionsPlugin) list(items ...interface{}) []interface{} {
+	if items == nil {
+		return []interface{}{}
+	}
+	return items
+}
+
+// append adds values to the end of a list given last: append 4 5 list. The
+// list keeps its type when the values fit it.
+func (f FunctionsPlugin) append(args ...interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, errors.New("append: expected values and a list")
+	}
+	list, values := args[len(args)-1], args[:len(args)-1]
+	if list == nil {
+		return values, nil
+	}
+
+	v, err := toSlice(list)
+	if err != nil {
+		return nil, err
+	}
+	out := reflect.MakeSlice(v.Type(), 0, v.Len()+len(values))
+	out = reflect.AppendSlice(out, v)
+	for _, value := range values {
+		rv := reflect.ValueOf(value)
+		if !rv.IsValid() || !rv.Type().AssignableTo(v.Type().Elem()) {
+			generic := make([]interface{}, 0, v.Len()+len(values))
+			for i := 0; i < v.Len(); i++ {
+				generic = append(generic, v.Index(i).Interface())
+			}
+			return append(generic, values...), nil
+		}
+		out = reflect.Append(out, rv)
+	}
+	return out.Interface(), nil
+}
+
+func (f FunctionsPlugin) safeHTML(s interface{}) template.HTML {
+	return template.HTML(text(s))
+}
+
+func (f FunctionsPlugin) safeURL(s interface{}) template.URL {
+	return template.URL(text(s))
+}
+
+// markdownify renders markdown to HTML. A single paragraph is returned
+// without its <p> so it can be used inline.
+func (f FunctionsPlugin) markdownify(s interface{}) template.HTML {
+	out := strings.TrimSpace(string(markdown.ToHTML([]byte(text(s)), nil, nil)))
+	if strings.HasPrefix(out, "<p>") && strings.HasSuffix(out, "</p>") && strings.Count(out, "<p>") == 1 {
+		out = strings.TrimSuffix(strings.TrimPrefix(out, "<p>"), "</p>")
+	}
+	return template.HTML(out)
+}
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// plainify strips the HTML tags of s.
+func (f FunctionsPlugin) plainify(s interface{}) string {
+	return htmlTags.ReplaceAllString(text(s), "")
+}
+
+func (f FunctionsPlugin) jsonify(v interface{}) (template.HTML, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(b), nil
+}
+
+// debug dumps a value as indented JSON, to see what a template gets, e.g.
+// <pre>{{ debug . }}</pre>. Values JSON can't encode, like cycles, are
+// printed with their Go syntax instead.
+func (f FunctionsPlugin) debug(v interface{}) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// urlize turns s into a lower case, hyphenated path segment.
+func (f FunctionsPlugin) urlize(s interface{}) string {
+	return slug.Make(text(s))
+}
+
+func (f FunctionsPlugin) base64Encode(s interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(text(s)))
+}
+
+func (f FunctionsPlugin) base64Decode(s interface{}) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(text(s))
+	return string(b), err
+}
+
+// defaultValue returns the given value unless it is empty: .Params.x |
+// default "none".
+func (f FunctionsPlugin) defaultValue(fallback, given interface{}) interface{} {
+	if given == nil {
+		return fallback
+	}
+	if rv := reflect.ValueOf(given); rv.IsZero() || (isList(rv) && rv.Len() == 0) {
+		return fallback
+	}
+	return given
+}
+
+// cond returns a when the condition holds, b otherwise: cond .Draft "draft" "".
+func (f FunctionsPlugin) cond(condition bool, a, b interface{}) interface{} {
+	if condition {
+		return a
+	}
+	return b
+}
+
+func isList(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// text reads a template value as a string, whatever string type it has.
+func text(v interface{}) string {
+	if s, ok := toString(v); ok {
+		return s
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
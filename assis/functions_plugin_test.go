@@ -0,0 +1,116 @@
+package assis
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"testing"
+)
+
+func TestFunctionsPlugin(t *testing.T) {
+	f := NewFunctionsPlugin()
+
+	t.Run("strings", func(t *testing.T) {
+		assert.Equal(t, "go", f.lower("Go"))
+		assert.Equal(t, "GO", f.upper(template.HTML("go")))
+		assert.Equal(t, "a-b", f.replace(" ", "-", "a b"))
+		assert.Equal(t, []string{"a", "b"}, f.split(",", "a,b"))
+
+		out, err := f.trim("  a ")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", out)
+		out, err = f.trim("/", "/a/")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", out)
+	})
+
+	t.Run("math", func(t *testing.T) {
+		sum, err := f.add(1, "2")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sum)
+
+		half, err := f.div(3, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1.5, half)
+
+		rest, err := f.mod(7, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, rest)
+
+		_, err = f.div(1, 0)
+		assert.Error(t, err)
+		_, err = f.sub("a", 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		seq, err := f.seq(3)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, seq)
+		seq, err = f.seq(3, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3, 2, 1}, seq)
+		seq, err = f.seq(0, 5, 12)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 5, 10}, seq)
+		seq, err = f.seq(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{}, seq)
+		seq, err = f.seq(-3)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{}, seq)
+
+		assert.Equal(t, []interface{}{"a", 1}, f.list("a", 1))
+		assert.Equal(t, []interface{}{}, f.list())
+
+		dict, err := f.dict("a", 1, "b", "two")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": 1, "b": "two"}, dict)
+		_, err = f.dict("a")
+		assert.Error(t, err)
+
+		list, err := f.append("c", []string{"a", "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, list)
+		list, err = f.append(3, []string{"a"})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"a", 3}, list)
+	})
+
+	t.Run("rendering and encoding", func(t *testing.T) {
+		assert.Equal(t, template.HTML("<em>a</em>"), f.markdownify("*a*"))
+		assert.Equal(t, "a b", f.plainify("<p>a <b>b</b></p>"))
+		assert.Equal(t, "hello-world", f.urlize("Hello World"))
+
+		encoded := f.base64Encode("assis")
+		decoded, err := f.base64Decode(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, "assis", decoded)
+
+		json, err := f.jsonify(map[string]int{"a": 1})
+		assert.NoError(t, err)
+		assert.Equal(t, template.HTML(`{"a":1}`), json)
+
+		assert.Equal(t, "{\n  \"a\": [\n    1\n  ]\n}", f.debug(map[string][]int{"a": {1}}))
+		assert.Contains(t, f.debug(map[string]interface{}{"f": func() {}}), "f:")
+	})
+
+	t.Run("default and cond", func(t *testing.T) {
+		assert.Equal(t, "none", f.defaultValue("none", ""))
+		assert.Equal(t, "none", f.defaultValue("none", nil))
+		assert.Equal(t, "none", f.defaultValue("none", []string{}))
+		assert.Equal(t, "set", f.defaultValue("none", "set"))
+		assert.Equal(t, "a", f.cond(true, "a", "b"))
+	})
+
+	t.Run("registered for every template", func(t *testing.T) {
+		gen := NewGenerator(Templates{}, nil).(SiteGenerator)
+		tpl, err := NewAssisTemplate(gen.funcMap(NewSnapshot(nil))).GetTemplate().Parse(
+			`{{ $d := dict "n" (add 1 2) }}{{ .Title | lower | urlize }} {{ index $d "n" }} {{ .Missing | default "x" }} {{ slice .Title 0 5 }} {{ len (list 1 2) }}{{ range seq 0 }}!{{ end }}`)
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		assert.NoError(t, tpl.Execute(&out, map[string]interface{}{"Title": "Hello World", "Missing": ""}))
+		assert.Equal(t, "hello-world 3 x Hello 2", out.String())
+	})
+}
@@ -1,61 +1,398 @@
 package assis
 
 import (
+	"bytes"
+	"context"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"html/template"
+	"io"
+	"io/ioutil"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
 )
 
 type AssisTemplate struct {
-	funcMap template.FuncMap
+	funcMap  template.FuncMap
+	strict   bool
+	snapshot *Snapshot
+	only     map[string]bool
+	parsed   *parseLog
+	rendered func(page *Page, output string, b []byte) ([]byte, error)
 }
 
 func NewAssisTemplate(funcMap template.FuncMap) AssisTemplate {
 	return AssisTemplate{funcMap: funcMap}
 }
 
+// Snapshot returns the snapshot of the build being rendered, nil outside of
+// one.
+func (a AssisTemplate) Snapshot() *Snapshot {
+	return a.snapshot
+}
+
 func (a AssisTemplate) GetTemplate() *template.Template {
-	return template.New(uuid.New().String()).Funcs(a.funcMap)
+	return template.New(uuid.New().String()).Funcs(a.funcMap).Option(a.missingKey())
+}
+
+// renders tells whether output is rendered: every output, unless the build
+// only renders some.
+func (a AssisTemplate) renders(output string) bool {
+	return a.only == nil || a.only[output]
+}
+
+// record keeps the files parsed for an output, with the templates each one
+// defines, and the template executed, when the build keeps them for Explain.
+func (a AssisTemplate) record(templates Templates, source, output string, files []string, defines map[string][]string, entry string) {
+	if a.parsed == nil {
+		return
+	}
+	record := parseRecord{entry: entry}
+	for _, file := range files {
+		if file != "" {
+			record.files = append(record.files, ExplainedFile{File: file, Role: templates.role(file, source), Defines: defines[file]})
+		}
+	}
+	a.parsed.add(output, record)
+}
+
+// missingKey is the template option for map keys templates read but the
+// data lacks: an error in strict mode, "<no value>" otherwise.
+func (a AssisTemplate) missingKey() string {
+	if a.strict {
+		return "missingkey=error"
+	}
+	return "missingkey=default"
+}
+
+// parse returns the templates for one page: a clone of the cached base set
+// with the templates of templateFile, if any, added on top so their blocks
+// take precedence.
+func (a AssisTemplate) parse(templates Templates, templateFile string) (*template.Template, error) {
+	cache := templates.cache
+	if cache == nil {
+		cache = NewTemplateCache()
+	}
+
+	target, err := cache.clone(templates.baseOrdered, a.GetTemplate)
+	if err != nil {
+		return nil, err
+	}
+	target.Funcs(a.funcMap).Option(a.missingKey())
+	if templateFile == "" {
+		return target, nil
+	}
+
+	parsed, err := cache.file(templateFile, a.GetTemplate)
+	if err != nil {
+		return nil, err
+	}
+	for _, tpl := range parsed.Templates() {
+		if tpl.Tree == nil {
+			continue
+		}
+		if _, err := target.AddParseTree(tpl.Name(), tpl.Tree.Copy()); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// defines returns the templates each of the cached files defines.
+func (a AssisTemplate) defines(templates Templates, files []string) map[string][]string {
+	if templates.cache == nil {
+		return map[string][]string{}
+	}
+	return templates.cache.definesOf(files)
+}
+
+// RenderLayout renders the base templates together with templateFile and
+// writes the "layout" template, executed with data, to output. Failures are
+// returned as a TemplateError. Pages of a plain text format are rendered with
+// renderText instead.
+func (a AssisTemplate) RenderLayout(templates Templates, templateFile, output string, data interface{}) error {
+	if !a.renders(output) {
+		return nil
+	}
+	content := output
+	page := pageOf(data)
+	if page != nil {
+		content = page.Source
+		if page.Format.PlainText {
+			return a.renderText(templates, templateFile, page, "", output, data)
+		}
+	}
+	files := append(append([]string{}, templates.baseOrdered...), templateFile)
+
+	targetTemplate, err := a.parse(templates, templateFile)
+	if err != nil {
+		return newTemplateError(content, files, err)
+	}
+	a.record(templates, content, output, files, a.defines(templates, files), "layout")
+
+	return a.write(page, output, func(w io.Writer) error {
+		return newTemplateError(content, files, targetTemplate.ExecuteTemplate(w, "layout", data))
+	})
+}
+
+// RenderPage renders a page whose own source is a template, like an HTML
+// content file, parsed after the base templates and templateFile, if any, so
+// its blocks take precedence. Failures are returned as a TemplateError.
+func (a AssisTemplate) RenderPage(templates Templates, templateFile string, page *Page) error {
+	if !a.renders(page.Output) {
+		return nil
+	}
+	if page.Format.PlainText {
+		return a.renderText(templates, templateFile, page, page.RawContent, page.Output, page)
+	}
+	files := append(append([]string{}, templates.baseOrdered...), templateFile, page.Source)
+
+	targetTemplate, err := a.parse(templates, templateFile)
+	if err != nil {
+		return newTemplateError(page.Source, files, err)
+	}
+	if _, err := targetTemplate.New(templateName(page.Source)).Parse(page.RawContent); err != nil {
+		return newTemplateError(page.Source, files, err)
+	}
+	defines := a.defines(templates, files)
+	defines[page.Source] = definesOf(targetTemplate, templateName(page.Source))
+	a.record(templates, page.Source, page.Output, files, defines, "layout")
+
+	return a.write(page, page.Output, func(w io.Writer) error {
+		return newTemplateError(page.Source, files, targetTemplate.ExecuteTemplate(w, "layout", page))
+	})
+}
+
+// renderText renders a page of a plain text format with text/template and the
+// same functions, so nothing is HTML escaped. The HTML base templates are left
+// out: only the partials with the extension of the format, templateFile and
+// the page source, when given, are parsed. The "layout" template is executed
+// when one of them defines it, else the page source, else templateFile.
+// Leading blank lines, left by the front matter, are dropped from the output.
+func (a AssisTemplate) renderText(templates Templates, templateFile string, page *Page, source, output string, data interface{}) error {
+	files := append(templates.Partials(page.Format.Extension), templateFile)
+	if source != "" {
+		files = append(files, page.Source)
+	}
+
+	target := texttemplate.New(uuid.New().String()).Funcs(texttemplate.FuncMap(a.funcMap)).Option(a.missingKey())
+	entry := ""
+	defines := map[string][]string{}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		text := source
+		if file != page.Source || source == "" {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			text = string(b)
+		}
+		if _, err := target.New(templateName(file)).Parse(text); err != nil {
+			return newTemplateError(page.Source, files, err)
+		}
+		var trees []*parse.Tree
+		for _, tpl := range target.Templates() {
+			trees = append(trees, tpl.Tree)
+		}
+		defines[file] = defined(trees, templateName(file))
+		if file == templateFile || strings.TrimSpace(text) != "" {
+			entry = templateName(file)
+		}
+	}
+	if target.Lookup("layout") != nil {
+		entry = "layout"
+	}
+	a.record(templates, page.Source, output, files, defines, entry)
+
+	var out bytes.Buffer
+	if entry != "" {
+		if err := target.ExecuteTemplate(&out, entry, data); err != nil {
+			return newTemplateError(page.Source, files, err)
+		}
+	}
+
+	return a.write(page, output, func(w io.Writer) error {
+		_, err := w.Write(bytes.TrimLeft(out.Bytes(), "\r\n"))
+		return err
+	})
+}
+
+// write renders an output in memory, passes it through the OnPageRendered
+// hooks and writes what they return to the output file.
+func (a AssisTemplate) write(page *Page, output string, render func(io.Writer) error) error {
+	var out bytes.Buffer
+	if err := render(&out); err != nil {
+		return err
+	}
+
+	b := out.Bytes()
+	if a.rendered != nil {
+		var err error
+		if b, err = a.rendered(page, output, b); err != nil {
+			return err
+		}
+	}
+
+	target, err := CreateTargetFile(output)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	_, err = target.Write(b)
+	return err
+}
+
+// onPageRendered runs the OnPageRendered hooks on an output, stopping once the
+// build is cancelled.
+func (h SiteGenerator) onPageRendered(site *Site) func(*Page, string, []byte) ([]byte, error) {
+	build := &BuildContext{Context: context.Background(), Config: h.templates.cfg, Logger: zap.NewNop()}
+	if h.build != nil {
+		*build = *h.build
+	}
+	build.Site = site
+
+	return func(page *Page, output string, b []byte) ([]byte, error) {
+		if err := build.Err(); err != nil {
+			return nil, err
+		}
+		for _, plugin := range h.rendered {
+			var err error
+			if b, err = plugin.OnPageRendered(build, page, output, b); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
 }
 
 type Generator interface {
-	Render(files SiteFiles) error
+	Render(site *Site, files SiteFiles) error
 }
 
 type SiteGenerator struct {
-	funcMap       template.FuncMap
-	plugins       []interface{}
-	templates     Templates
-	assisTemplate AssisTemplate
+	functions []interface{}
+	plugins   []interface{}
+	templates Templates
+	build     *BuildContext
+	snapshot  *Snapshot
+	only      map[string]bool
+	parsed    *parseLog
+	rendered  []PluginPageRendered
+	err       error
 }
 
+// NewGenerator registers the plugins, with the built-in FunctionsPlugin
+// unless one is given, and collects their template functions in the order
+// of the registry, which puts the built-in functions first so a plugin can
+// override them.
 func NewGenerator(templates Templates, plugins []interface{}) Generator {
-	funcMap := make(map[string]interface{}, len(plugins))
-	for _, plugin := range plugins {
-		switch plugin := plugin.(type) {
-		case PluginCustomFunction:
-			for name, fun := range plugin.OnRegisterCustomFunction() {
-				funcMap[name] = fun
+	return newGenerator(templates, plugins, nil, nil)
+}
+
+// newGenerator makes a generator whose OnPageRendered hooks get build, or a
+// context of their own when it is nil, and rendering from snapshot, or from a
+// snapshot of the site it renders when it is nil.
+func newGenerator(templates Templates, plugins []interface{}, build *BuildContext, snapshot *Snapshot) SiteGenerator {
+	registry, err := NewPluginRegistry(plugins...)
+	if err == nil && !registry.Has("functions") {
+		err = registry.Register(NewFunctionsPlugin())
+	}
+	if templates.cfg != nil {
+		registry.setOrder(templates.cfg.pluginOrder())
+	}
+
+	var functions []interface{}
+	var rendering []interface{}
+	if err == nil {
+		functions, err = registry.Ordered(HookCustomFunction)
+	}
+	if err == nil {
+		rendering, err = registry.Ordered(HookRender)
+	}
+	var rendered []PluginPageRendered
+	if err == nil {
+		var ordered []interface{}
+		ordered, err = registry.Ordered(HookPageRendered)
+		for _, plugin := range ordered {
+			if plugin, ok := plugin.(PluginPageRendered); ok {
+				rendered = append(rendered, plugin)
 			}
 		}
 	}
 
 	return SiteGenerator{
-		funcMap:       funcMap,
-		plugins:       plugins,
-		templates:     templates,
-		assisTemplate: NewAssisTemplate(funcMap),
+		functions: functions,
+		plugins:   rendering,
+		templates: templates,
+		build:     build,
+		snapshot:  snapshot,
+		rendered:  rendered,
+		err:       err,
 	}
 }
 
-func (h SiteGenerator) Render(siteFiles SiteFiles) error {
+// funcMap returns the template functions of the plugins, in the order of the
+// registry, the ones reading from the snapshot bound to it.
+func (h SiteGenerator) funcMap(snapshot *Snapshot) template.FuncMap {
+	funcMap := template.FuncMap{}
+	for _, plugin := range h.functions {
+		if plugin, ok := plugin.(PluginCustomFunction); ok {
+			for name, fun := range plugin.OnRegisterCustomFunction() {
+				funcMap[name] = fun
+			}
+		}
+		if plugin, ok := plugin.(PluginSnapshotFunction); ok {
+			for name, fun := range plugin.OnRegisterSnapshotFunction(snapshot) {
+				funcMap[name] = fun
+			}
+		}
+	}
+	return funcMap
+}
+
+// Render runs every PluginRender, in the order of the registry, reading from
+// the snapshot of the site. Templates can also reach the site through
+// the "site" function, for partials called without the page as context, and
+// call partials with a context of their own through "partial" and
+// "partialCached", whose results are kept for the build.
+// Every plugin runs even when one fails; the errors are returned together.
+func (h SiteGenerator) Render(site *Site, siteFiles SiteFiles) error {
+	if h.err != nil {
+		return h.err
+	}
+	snapshot := h.snapshot
+	if snapshot == nil {
+		snapshot = NewSnapshot(site)
+	}
+
+	funcMap := template.FuncMap{
+		"site": func() *Site { return site },
+	}
+	assisTemplate := NewAssisTemplate(funcMap)
+	assisTemplate.strict = h.templates.cfg != nil && h.templates.cfg.Strict
+	assisTemplate.snapshot = snapshot
+	assisTemplate.only = h.only
+	assisTemplate.parsed = h.parsed
+	assisTemplate.rendered = h.onPageRendered(site)
+
+	partials := newPartialRenderer(h.templates, assisTemplate)
+	funcMap["partial"] = partials.partial
+	funcMap["partialCached"] = partials.partialCached
+	for name, fun := range h.funcMap(snapshot) {
+		funcMap[name] = fun
+	}
+
+	var errs errorCollector
 	for i := 0; i < len(h.plugins); i++ {
 		switch plugin := h.plugins[i].(type) {
 		case PluginRender:
-			if err := plugin.OnRender(h.assisTemplate, siteFiles, h.templates); err != nil {
-				return err
-			}
+			errs.add(plugin.OnRender(assisTemplate, site, siteFiles, h.templates))
 			break
 		}
 	}
-	return nil
+	return errs.err()
 }
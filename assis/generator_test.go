@@ -1,11 +1,34 @@
 package assis
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
+// collectionsOnly registers the article collections without rendering the
+// articles.
+type collectionsOnly struct {
+	articles ArticlePlugin
+}
+
+func (c collectionsOnly) OnRegisterSnapshotFunction(snapshot *Snapshot) map[string]interface{} {
+	return c.articles.OnRegisterSnapshotFunction(snapshot)
+}
+
+func (c collectionsOnly) OnRegisterCustomFunction() map[string]interface{} {
+	return c.articles.OnRegisterCustomFunction()
+}
+
 func TestHTMLGenerator_Render(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	config := NewDefaultConfig("./mock/_site")
@@ -13,27 +36,508 @@ func TestHTMLGenerator_Render(t *testing.T) {
 	assis.LoadFilesAsync()
 
 	t.Run("generate HTML", func(t *testing.T) {
-		gen := NewGenerator(assis.templates, []interface{}{NewHTMLPlugin(config, logger)})
-		err := gen.Render(assis.container)
+		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger), NewHTMLPlugin(config, logger)})
+		err := gen.Render(assis.site, assis.container)
 		assert.NoError(t, err)
 	})
 
 	t.Run("generate Article", func(t *testing.T) {
 		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger)})
-		err := gen.Render(assis.container)
+		err := gen.Render(assis.site, assis.container)
 		assert.NoError(t, err)
 	})
 
 	t.Run("test markdown custom function", func(t *testing.T) {
 		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger), NewHTMLPlugin(config, logger)})
-		err := gen.Render(assis.container)
+		err := gen.Render(assis.site, assis.container)
+		assert.NoError(t, err)
+	})
+
+	t.Run("recursive and site-wide article collections", func(t *testing.T) {
+		snapshot, err := assis.Collect()
+		assert.NoError(t, err)
+
+		assert.Len(t, snapshot.collection("/articles", false, false), 3)
+		assert.Len(t, snapshot.collection("/articles/posts", false, false), 1)
+		assert.Len(t, snapshot.collection("/articles", false, true), 4)
+		assert.Len(t, snapshot.allArticles(), 4)
+
+		for _, article := range snapshot.collection("/articles/posts", false, false) {
+			assert.Equal(t, "/articles/posts", article.SectionPath)
+		}
+
+		functions := NewArticlePlugin(config, logger).OnRegisterSnapshotFunction(snapshot)
+		assert.Len(t, functions["articleCollectionRecursive"].(func(string) []Article)("/articles"), 4)
+	})
+
+	t.Run("collections are complete before any article is rendered", func(t *testing.T) {
+		gen := NewGenerator(assis.templates, []interface{}{NewHTMLPlugin(config, logger), collectionsOnly{NewArticlePlugin(config, logger)}})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/index.html")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, strings.Count(string(b), "title-4 | Title 4</li>"))
+		assert.Contains(t, string(b), "<li>/articles/posts title | Title</li>")
+	})
+
+	t.Run("title data pages without a title field after their page", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		site := NewSite(cfg)
+		site.Data = map[string]interface{}{"people": []interface{}{
+			map[string]interface{}{"slug": "ana-maria", "name": "Ana"},
+			map[string]interface{}{"slug": "joao-silva"},
+		}}
+
+		pages, err := NewDataPagePlugin(cfg, logger).buildPages(DataPage{Name: "people", Source: "people", Permalink: "/people/:slug", TitleField: "name"}, site)
+		assert.NoError(t, err)
+		assert.Equal(t, "Ana", pages[0].Title)
+		assert.Equal(t, "Joao silva", pages[1].Title)
+	})
+
+	t.Run("generate pages from data records", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.DataPages = []DataPage{{
+			Name:       "plans",
+			Source:     "pricing.csv",
+			Template:   "plan_layout.html",
+			Permalink:  "/pricing/:plan",
+			TitleField: "plan",
+			List:       &DataPageList{Title: "Pricing", Template: "plan_list.html", Permalink: "/pricing"},
+		}}
+
+		plans := NewDataPagePlugin(cfg, logger)
+		site := NewAssis(cfg, []interface{}{plans}, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+
+		gen := NewGenerator(site.templates, []interface{}{plans})
+		assert.NoError(t, gen.Render(site.site, site.container))
+
+		pages := plans.dataPages("plans")
+		assert.Len(t, pages, 2)
+		assert.Equal(t, "pricing/basic.html", pages[0].Permalink)
+		assert.Equal(t, "/pricing", pages[0].SectionPath)
+		assert.FileExists(t, "./mock/_site/output/pricing/pro.html")
+		assert.FileExists(t, "./mock/_site/output/pricing/index.html")
+		assert.Len(t, site.site.PagesByKind(KindData), 2)
+		assert.Len(t, site.site.GetPage("pricing/index.html").Pages, 2)
+	})
+
+	t.Run("site params in every template", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Title = "Snippetbox"
+		cfg.BaseURL = "https://example.com/"
+		cfg.Params = map[string]interface{}{"description": "A mock site"}
+
+		site := NewAssis(cfg, nil, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+		assert.Equal(t, "https://example.com/about.html", site.site.AbsURL("/about.html"))
+
+		gen := NewGenerator(site.templates, []interface{}{NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)})
+		assert.NoError(t, gen.Render(site.site, site.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "Sobre - Snippetbox")
+		assert.Contains(t, string(b), "A mock site")
+	})
+
+	t.Run("front matter on HTML pages", func(t *testing.T) {
+		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger), NewHTMLPlugin(config, logger)})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), "---")
+		assert.Contains(t, string(b), "<title>Sobre - ")
+		assert.Contains(t, string(b), "Sobre o site por Ana")
+		assert.Contains(t, string(b), `<a href="/about.html">/</a>`)
+	})
+
+	t.Run("partials with a context of their own", func(t *testing.T) {
+		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger), NewHTMLPlugin(config, logger)})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "<h3>Artigos recentes</h3>")
+		assert.Contains(t, string(b), `<a href="/articles/title-1.html">Title 1</a>`)
+
+		partials := newPartialRenderer(assis.templates, NewAssisTemplate(template.FuncMap{}))
+		out, err := partials.partial("recent.html", map[string]interface{}{"title": "One"})
+		assert.NoError(t, err)
+		assert.Contains(t, string(out.(template.HTML)), "<h3>One</h3>")
+		_, err = partials.partial("missing")
+		assert.Error(t, err)
+
+		first, err := partials.partialCached("recent", map[string]interface{}{"title": "One"}, "a")
+		assert.NoError(t, err)
+		again, err := partials.partialCached("recent", map[string]interface{}{"title": "Two"}, "a")
+		assert.NoError(t, err)
+		other, err := partials.partialCached("recent", map[string]interface{}{"title": "Two"}, "b")
+		assert.NoError(t, err)
+		assert.Equal(t, first, again)
+		assert.Contains(t, string(other.(template.HTML)), "<h3>Two</h3>")
+	})
+
+	t.Run("plain text partials are escaped in HTML", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "note.txt")
+		assert.NoError(t, ioutil.WriteFile(file, []byte("<b>{{ .name }}</b>"), 0644))
+		templates := NewTemplates(config)
+		templates.addPartial("note.txt", file)
+
+		partials := newPartialRenderer(templates, NewAssisTemplate(template.FuncMap{}))
+		out, err := partials.partial("note.txt", map[string]interface{}{"name": "Ana"})
+		assert.NoError(t, err)
+		assert.Equal(t, "<b>Ana</b>", out)
+
+		tpl := template.Must(template.New("page").Funcs(template.FuncMap{"partial": partials.partial}).Parse(`{{ partial "note.txt" . }}`))
+		var b strings.Builder
+		assert.NoError(t, tpl.Execute(&b, map[string]interface{}{"name": "Ana"}))
+		assert.Equal(t, "&lt;b&gt;Ana&lt;/b&gt;", b.String())
+	})
+
+	t.Run("cached partials render once per key under concurrency", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "count.txt")
+		assert.NoError(t, ioutil.WriteFile(file, []byte("{{ count }}"), 0644))
+		templates := NewTemplates(config)
+		templates.addPartial("count.txt", file)
+
+		var renders int32
+		count := func() int32 { return atomic.AddInt32(&renders, 1) }
+		partials := newPartialRenderer(templates, NewAssisTemplate(template.FuncMap{"count": count}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				out, err := partials.partialCached("count.txt", nil, "key")
+				assert.NoError(t, err)
+				assert.Equal(t, "1", out)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), renders)
+	})
+
+	t.Run("non-HTML output formats", func(t *testing.T) {
+		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger), NewHTMLPlugin(config, logger)})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		feed := assis.site.GetPage("feed.xml")
+		assert.Equal(t, "rss", feed.Format.Name)
+		assert.Equal(t, "application/rss+xml", feed.Format.MediaType)
+		b, err := ioutil.ReadFile("./mock/_site/output/feed.xml")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(b), `<?xml version="1.0"`))
+		assert.Contains(t, string(b), "<title>Feed & more</title>")
+		assert.Contains(t, string(b), "<title>Title 1</title>")
+
+		b, err = ioutil.ReadFile("./mock/_site/output/manifest.webmanifest")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"name": "Assis' site"`)
+		assert.Contains(t, string(b), `"description": "A static site"`)
+		assert.NotContains(t, string(b), "&#34;")
+
+		_, err = formatOf(&Page{Source: "x.html", Params: map[string]interface{}{"format": "pdf"}}, config.AllOutputFormats(), nil)
+		assert.Error(t, err)
+		format, err := formatOf(&Page{Layout: "data.json", Params: map[string]interface{}{}}, config.AllOutputFormats(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "json", format.Name)
+	})
+
+	t.Run("multiple outputs per page", func(t *testing.T) {
+		gen := NewGenerator(assis.templates, []interface{}{NewArticlePlugin(config, logger)})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		article := assis.site.GetPage("articles/title-1.html")
+		assert.Len(t, article.Outputs(), 2)
+		alternatives := article.AlternativeOutputs()
+		assert.Len(t, alternatives, 1)
+		assert.Equal(t, "articles/title-1.json", alternatives[0].Permalink)
+		assert.Equal(t, []*Page{article}, alternatives[0].AlternativeOutputs())
+
+		b, err := ioutil.ReadFile("./mock/_site/output/articles/title-1.json")
+		assert.NoError(t, err)
+		var decoded map[string]interface{}
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, "Title 1", decoded["title"])
+		assert.Equal(t, []interface{}{"Ana"}, decoded["authors"])
+
+		b, err = ioutil.ReadFile("./mock/_site/output/articles/title-1.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `<link rel="alternate" type="application/json" href="/articles/title-1.json">`)
+
+		page := &Page{Kind: KindPage, Source: "about.html", Output: "out/about.html", Permalink: "about.html",
+			Params: map[string]interface{}{"outputs": []interface{}{"html", "amp", "json"}}}
+		assert.NoError(t, page.setOutputs(config.AllOutputFormats(), nil))
+		var permalinks []string
+		for _, output := range page.Outputs() {
+			permalinks = append(permalinks, output.Permalink)
+		}
+		assert.Equal(t, []string{"about.html", "about.amp.html", "about.json"}, permalinks)
+
+		page = &Page{Kind: KindPage, Source: "about.html", Output: "out/about.html", Permalink: "about.html", Params: map[string]interface{}{}}
+		assert.NoError(t, page.setOutputs(config.AllOutputFormats(), map[string][]string{KindPage: {"html", "text"}}))
+		assert.Equal(t, "about.txt", page.AlternativeOutputs()[0].Permalink)
+		assert.Error(t, page.setOutputs(config.AllOutputFormats(), map[string][]string{KindPage: {"pdf"}}))
+
+		templates := assis.templates
+		file, err := templates.Lookup(alternatives[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "mock/_site/template/_default/single.json.json", file)
+	})
+
+	t.Run("site graph", func(t *testing.T) {
+		site := assis.site
+		assert.Len(t, site.PagesByKind(KindPage), 7)
+		assert.Len(t, site.PagesByKind(KindArticle), 4)
+		assert.Len(t, site.GetSection("/articles").Pages, 4)
+		assert.Len(t, site.PagesByKind(KindSection), 2)
+		assert.Len(t, site.GetSection("articles/posts").Pages, 1)
+
+		about := site.GetPage("/about.html")
+		assert.Equal(t, KindPage, about.Kind)
+		assert.Equal(t, "Sobre", about.Title)
+		assert.Equal(t, "mock/_site/output/about.html", about.Output)
+
+		article := site.GetPage("articles/title-1.html")
+		assert.Equal(t, KindArticle, article.Kind)
+		assert.Equal(t, "mock/_site/content/articles/article1.md", article.Source)
+		assert.Contains(t, string(article.Content), "<h1>Markdown: Basics</h1>")
+	})
+
+	t.Run("section list pages", func(t *testing.T) {
+		articles := assis.site.GetSection("/articles")
+		assert.Equal(t, "Articles", articles.Title)
+		assert.Equal(t, "Every article of the site", articles.Description)
+		assert.Equal(t, KindSection, articles.Index.Kind)
+		assert.Equal(t, "articles/index.html", articles.Index.Permalink)
+		assert.Equal(t, "2022-01-01", articles.Pages[0].Date)
+		assert.Equal(t, "Posts", articles.Sections[0].Title)
+
+		gen := NewGenerator(assis.templates, []interface{}{NewSectionPlugin(config, logger)})
+		assert.NoError(t, gen.Render(assis.site, assis.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/articles/index.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "<em>mock</em>")
+		assert.Contains(t, string(b), `<a href="/articles/title-1.html">Title 1</a>`)
+		assert.Contains(t, string(b), `<a href="/articles/posts/index.html">Posts</a>`)
+
+		b, err = ioutil.ReadFile("./mock/_site/output/articles/posts/index.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), "<title>All posts")
+	})
+
+	t.Run("section tree", func(t *testing.T) {
+		tree := assis.site.Tree
+		assert.Equal(t, "/", tree.Path)
+		assert.Nil(t, tree.Parent)
+
+		post := assis.site.GetPage("articles/posts/title.html")
+		assert.Equal(t, "/articles/posts", post.Section.Path)
+		assert.Equal(t, post.Section, post.Parent())
+		assert.Equal(t, []*Section{tree, assis.site.GetSection("/articles"), post.Section}, post.Ancestors())
+		assert.Empty(t, post.Children())
+		assert.True(t, assis.site.GetSection("/articles").Contains(post))
+
+		index := assis.site.GetPage("articles/posts/index.html")
+		assert.Equal(t, assis.site.GetSection("/articles"), index.Parent())
+		assert.Len(t, index.Children(), 1)
+		assert.Equal(t, "index.html", tree.Permalink())
+
+		children := tree.Children()
+		assert.Equal(t, "Articles", children[0].Title)
+		assert.True(t, children[0].IsSection())
+		assert.Equal(t, "articles/index.html", children[0].Permalink)
+
+		b, err := ioutil.ReadFile("./mock/_site/output/articles/index.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `<nav class="breadcrumbs"><a href="/index.html"></a> / </nav>`)
+		assert.Contains(t, string(b), `<a href="/articles/index.html">Articles</a> (open)`)
+	})
+
+	t.Run("ordering by weight and title fallbacks", func(t *testing.T) {
+		site := &Site{Sections: map[string]*Section{}}
+		for _, page := range []*Page{
+			{Kind: KindPage, file: "zeta.html", rel: "docs", SectionPath: "/docs", Params: map[string]interface{}{}},
+			{Kind: KindPage, file: "getting-started.html", rel: "docs", SectionPath: "/docs", Params: map[string]interface{}{}},
+			{Kind: KindPage, file: "ações.html", rel: "docs", SectionPath: "/docs", Params: map[string]interface{}{}},
+			{Kind: KindPage, file: "install.html", rel: "docs", SectionPath: "/docs", Params: map[string]interface{}{"title": "Install", "weight": 1}},
+		} {
+			page.resolve()
+			site.Pages = append(site.Pages, page)
+		}
+		site.Index()
+		section := site.GetSection("/docs")
+
+		var titles []string
+		for _, node := range section.Children() {
+			titles = append(titles, node.Title)
+		}
+		assert.Equal(t, []string{"Install", "Ações", "Getting started", "Zeta"}, titles)
+		assert.Equal(t, "Docs", section.Title)
+		assert.Equal(t, "Índice geral", humanize("índice_geral"))
+	})
+
+	t.Run("menus from config and front matter", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Menus = map[string][]MenuItem{"main": {
+			{Name: "Home", Page: "index.html", Weight: 1},
+			{Name: "GitHub", URL: "https://github.com/luizfsnunes/assis", Weight: 10},
+		}}
+
+		site := NewAssis(cfg, nil, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+
+		main := site.site.Menus["main"]
+		var names []string
+		for _, entry := range main {
+			names = append(names, entry.Name)
+		}
+		assert.Equal(t, []string{"Home", "Articles", "Sobre", "GitHub"}, names)
+		assert.True(t, main[3].IsExternal())
+		assert.Equal(t, "/articles/posts/index.html", main[1].Children[0].URL)
+
+		post := site.site.GetPage("articles/posts/title.html")
+		assert.True(t, main[1].HasCurrent(post))
+		assert.False(t, main[1].IsCurrent(post))
+		assert.True(t, main[1].Children[0].HasCurrent(Article{Page: post}))
+		assert.True(t, main[2].IsCurrent(site.site.GetPage("about.html")))
+
+		gen := NewGenerator(site.templates, []interface{}{NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger), NewSectionPlugin(cfg, logger)})
+		assert.NoError(t, gen.Render(site.site, site.container))
+
+		b, err := ioutil.ReadFile("./mock/_site/output/about.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `<li class="active"><a href="/about.html">Sobre</a></li>`)
+
+		b, err = ioutil.ReadFile("./mock/_site/output/articles/posts/index.html")
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `<li class="open"><a href="/articles/index.html">Articles</a></li>`)
+	})
+
+	t.Run("menu errors", func(t *testing.T) {
+		_, err := newMenu([]*MenuEntry{{Name: "A", Parent: "missing"}})
+		assert.Error(t, err)
+		_, err = newMenu([]*MenuEntry{{Name: "A", Parent: "B"}, {Name: "B", Parent: "A"}})
+		assert.Error(t, err)
+		_, err = newMenu([]*MenuEntry{{Name: "A"}, {Name: "A"}})
+		assert.Error(t, err)
+		assert.Error(t, checkConfigMenus(map[string][]MenuItem{"main": {{Name: "A"}}}))
+	})
+
+	t.Run("cascading front matter defaults", func(t *testing.T) {
+		post := assis.site.GetPage("articles/posts/title.html")
+		assert.Equal(t, "A post of the mock site", post.Description)
+		assert.Equal(t, "mock/_site/content/articles/posts/_defaults.yaml", post.Inherited["description"])
+		assert.Equal(t, "Ana", post.Params["authors"])
+		assert.Equal(t, "mock/_site/content/articles/_index.md", post.Inherited["authors"])
+
+		own := assis.site.GetPage("articles/title-1.html")
+		assert.Equal(t, "Ana", own.Params["authors"])
+		assert.NotContains(t, own.Inherited, "description")
+
+		html := assis.site.GetPage("articles/article3.html")
+		assert.NotContains(t, html.Params, "authors")
+		assert.Len(t, assis.site.Taxonomies["authors"]["Ana"], 4)
+	})
+
+	t.Run("template lookup order", func(t *testing.T) {
+		lookup := func(page *Page) string {
+			file, err := assis.templates.Lookup(page)
+			assert.NoError(t, err)
+			return file
+		}
+
+		assert.Equal(t, "mock/_site/template/articles/posts/single.html", lookup(assis.site.GetPage("articles/posts/title.html")))
+		assert.Equal(t, "mock/_site/template/articles/single.html", lookup(assis.site.GetPage("articles/title-1.html")))
+		assert.Equal(t, "mock/_site/template/_default/single.html", lookup(assis.site.GetPage("subpage/page.html")))
+		assert.Equal(t, "mock/_site/template/_default/list.html", lookup(assis.site.GetPage("articles/index.html")))
+		assert.Equal(t, "mock/_site/template/plan_layout.html", lookup(&Page{Layout: "plan_layout.html", SectionPath: "/articles"}))
+
+		_, err := assis.templates.Lookup(&Page{Source: "missing.md", Layout: "missing.html"})
+		assert.Error(t, err)
+
+		b, err := ioutil.ReadFile("./mock/_site/output/articles/title-1.html")
 		assert.NoError(t, err)
+		assert.Contains(t, string(b), `<div class="article">`)
+	})
+
+	t.Run("template errors point at the faulty line", func(t *testing.T) {
+		dir := t.TempDir()
+		source := filepath.ToSlash(filepath.Join(dir, "broken.html"))
+		content := "{{template \"layout\" .}}\n{{define \"title\"}}Broken{{end}}\n{{define \"body\"}}\n<p>{{ .Nope }}</p>\n{{end}}\n"
+		assert.NoError(t, ioutil.WriteFile(source, []byte(content), 0644))
+
+		page := &Page{Source: source, Output: filepath.Join(dir, "out.html"), RawContent: content, Site: assis.site}
+		err := NewAssisTemplate(template.FuncMap{"site": func() *Site { return assis.site }}).RenderPage(assis.templates, "", page)
+
+		var templateError *TemplateError
+		assert.True(t, errors.As(err, &templateError))
+		assert.Equal(t, source, templateError.Content)
+		assert.Equal(t, source, templateError.Template)
+		assert.Equal(t, 4, templateError.Line)
+		assert.Contains(t, templateError.Excerpt, "> ")
+		assert.Contains(t, templateError.Excerpt, "{{ .Nope }}")
+	})
+
+	t.Run("template errors tell apart files sharing a name", func(t *testing.T) {
+		dir := filepath.ToSlash(t.TempDir())
+		templateFile := dir + "/template/single.html"
+		source := dir + "/content/single.html"
+		assert.NoError(t, os.MkdirAll(dir+"/template", 0755))
+		assert.NoError(t, os.MkdirAll(dir+"/content", 0755))
+		assert.NoError(t, ioutil.WriteFile(templateFile, []byte("{{define \"body\"}}\n<p>{{ .Nope }}</p>\n{{end}}\n"), 0644))
+		content := "{{template \"layout\" .}}\n{{define \"title\"}}Fine{{end}}\n"
+		assert.NoError(t, ioutil.WriteFile(source, []byte(content), 0644))
+
+		page := &Page{Source: source, Output: dir + "/out.html", RawContent: content, Site: assis.site}
+		err := NewAssisTemplate(template.FuncMap{"site": func() *Site { return assis.site }}).RenderPage(assis.templates, templateFile, page)
+
+		var templateError *TemplateError
+		assert.True(t, errors.As(err, &templateError))
+		assert.Equal(t, templateFile, templateError.Template)
+		assert.Equal(t, 2, templateError.Line)
+		assert.Contains(t, templateError.Excerpt, "{{ .Nope }}")
+	})
+
+	t.Run("render errors fail the build", func(t *testing.T) {
+		site := NewAssis(config, nil, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+		gen := NewGenerator(site.templates, []interface{}{NewHTMLPlugin(config, logger)})
+		err := gen.Render(site.site, site.container)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "index.html")
+		assert.Contains(t, err.Error(), `function "articleCollection" not defined`)
+	})
+
+	t.Run("strict mode fails on missing keys", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Strict = true
+
+		site := NewAssis(cfg, nil, logger)
+		assert.NoError(t, site.LoadFilesAsync())
+		gen := NewGenerator(site.templates, []interface{}{NewArticlePlugin(cfg, logger), NewHTMLPlugin(cfg, logger)})
+		err := gen.Render(site.site, site.container)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `map has no entry for key "description"`)
+
+		var templateError *TemplateError
+		assert.True(t, errors.As(err.(RenderErrors)[0], &templateError))
+		assert.Equal(t, "mock/_site/template/layout.html", templateError.Template)
+
+		site.site.Params["description"] = "A mock site"
+		assert.NoError(t, gen.Render(site.site, site.container))
 	})
 
 	t.Run("copy static files", func(t *testing.T) {
 		p := NewStaticFilesPlugin(config, []string{".js", ".png", ".jpg", ".jpeg", ".gif", ".css"}, logger)
 		gen := NewGenerator(assis.templates, []interface{}{p})
-		err := gen.Render(assis.container)
+		err := gen.Render(assis.site, assis.container)
 		assert.NoError(t, err)
 	})
 }
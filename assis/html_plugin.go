@@ -4,7 +4,6 @@ import (
 	"github.com/gammazero/workerpool"
 	"go.uber.org/zap"
 	"html/template"
-	"path/filepath"
 )
 
 type HTMLPlugin struct {
@@ -17,6 +16,12 @@ func NewHTMLPlugin(config *Config, logger *zap.Logger) HTMLPlugin {
 	return HTMLPlugin{config: config, name: "html", logger: logger}
 }
 
+// Describe renders HTML pages after the articles, whose collections they can
+// list.
+func (h HTMLPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "html", Version: builtinVersion, After: []string{"articles"}}
+}
+
 func (h HTMLPlugin) OnRegisterCustomFunction() map[string]interface{} {
 	return map[string]interface{}{
 		"truncate": h.Truncate,
@@ -35,16 +40,15 @@ func (h HTMLPlugin) Truncate(size int, str template.HTML) template.HTML {
 	return str
 }
 
-func (h HTMLPlugin) OnRender(t AssisTemplate, siteFiles SiteFiles, templates Templates) error {
+func (h HTMLPlugin) OnRender(t AssisTemplate, site *Site, siteFiles SiteFiles, templates Templates) error {
 	h.logger.Info("Start HTML rendering")
+	var errs errorCollector
 	wp := workerpool.New(2)
 	maxJobs := 0
-	for _, container := range siteFiles {
-		container := container
+	for _, section := range site.Sections {
+		section := section
 		wp.Submit(func() {
-			if err := h.processContainer(container, t, templates); err != nil {
-				h.logger.Error(err.Error())
-			}
+			errs.add(h.processSection(section, t, templates))
 		})
 		maxJobs += 1
 		if maxJobs == 4 {
@@ -55,34 +59,32 @@ func (h HTMLPlugin) OnRender(t AssisTemplate, siteFiles SiteFiles, templates Tem
 	}
 	wp.StopWait()
 	h.logger.Info("Finished HTML rendering")
-	return nil
+	return errs.err()
 }
 
-func (h HTMLPlugin) processContainer(container *FileContainer, t AssisTemplate, templates Templates) error {
-	files := container.FilterExt([]string{HTML})
-	for _, file := range files {
-		filename := filepath.ToSlash(container.FullFilename(file))
-
-		allTemplates := append(templates.GetTemplatesByDir(filename), filename)
-		targetTemplate, err := t.GetTemplate().ParseFiles(allTemplates...)
+// processSection renders the HTML pages of a section, carrying on past the
+// pages that fail.
+func (h HTMLPlugin) processSection(section *Section, t AssisTemplate, templates Templates) error {
+	var errs RenderErrors
+	for _, page := range section.Pages {
+		if page.Kind != KindPage {
+			continue
+		}
 
-		err = func() error {
-			target, err := CreateTargetFile(container.OutputFilename(file))
-			defer target.Close()
-			if err != nil {
-				return err
+		for _, output := range page.Outputs() {
+			templateFile, err := templates.Lookup(output)
+			if err == nil {
+				err = t.RenderPage(templates, templateFile, output)
 			}
-
-			if err = targetTemplate.ExecuteTemplate(target, "layout", nil); err != nil {
-				return err
+			if err != nil {
+				errs = append(errs, err)
+				continue
 			}
-
-			h.logger.Info("Rendered file to " + target.Name())
-			return nil
-		}()
-		if err != nil {
-			return err
+			h.logger.Info("Rendered file to " + output.Output)
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
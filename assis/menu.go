@@ -0,0 +1,217 @@
+package assis
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MenuEntry is one link of a menu, built from the menus of the config or from
+// the menu front matter of a page. Children holds the entries naming it as
+// their parent.
+type MenuEntry struct {
+	Identifier string
+	Name       string
+	URL        string
+	Weight     int
+	Parent     string
+	Page       *Page `json:"-"`
+	Children   Menu
+}
+
+// Menu is a list of entries ordered by weight and then name.
+type Menu []*MenuEntry
+
+// Menus maps the name of each menu, e.g. "main", to its top level entries.
+type Menus map[string]Menu
+
+// IsExternal tells whether the entry links outside the site.
+func (e *MenuEntry) IsExternal() bool {
+	if e.Page != nil {
+		return false
+	}
+	u, err := url.Parse(e.URL)
+	return err == nil && (u.IsAbs() || strings.HasPrefix(e.URL, "//"))
+}
+
+func (e *MenuEntry) HasChildren() bool {
+	return len(e.Children) > 0
+}
+
+// IsCurrent tells whether the entry links to the page being rendered. It takes
+// the template data, a page or an article, so layouts can call it with $.
+func (e *MenuEntry) IsCurrent(current interface{}) bool {
+	page := pageOf(current)
+	if page == nil {
+		return false
+	}
+	if e.Page != nil {
+		return e.Page == page
+	}
+	return !e.IsExternal() && strings.Trim(e.URL, "/") == page.Permalink
+}
+
+// HasCurrent tells whether the page being rendered is below the entry: one
+// of its children is current, or it links to a section holding the page.
+func (e *MenuEntry) HasCurrent(current interface{}) bool {
+	page := pageOf(current)
+	if page == nil {
+		return false
+	}
+	for _, child := range e.Children {
+		if child.IsCurrent(page) || child.HasCurrent(page) {
+			return true
+		}
+	}
+	if e.Page == nil || e.Page == page || e.Page.Kind != KindSection || e.Page.SectionPath == "/" {
+		return false
+	}
+	return e.Page.Section != nil && e.Page.Section.Contains(page)
+}
+
+// pageOf returns the page behind the data of a template.
+func pageOf(data interface{}) *Page {
+	switch v := data.(type) {
+	case *Page:
+		return v
+	case Article:
+		return v.Page
+	case *Article:
+		return v.Page
+	}
+	return nil
+}
+
+// buildMenus builds Menus from the menus of the config and the menu front
+// matter of every page. It runs once the pages are indexed, so entries can
+// point at any page.
+func (s *Site) buildMenus() error {
+	entries := map[string][]*MenuEntry{}
+	for name, items := range s.menus {
+		for _, item := range items {
+			entry := &MenuEntry{Identifier: item.Identifier, Name: item.Name, URL: item.URL, Weight: item.Weight, Parent: item.Parent}
+			if item.Page != "" {
+				if entry.Page = s.GetPage(item.Page); entry.Page == nil {
+					return fmt.Errorf("menus.%s: no page %q", name, item.Page)
+				}
+			}
+			entries[name] = append(entries[name], entry)
+		}
+	}
+
+	for _, page := range s.Pages {
+		pageEntries, err := pageMenus(page)
+		if err != nil {
+			return fmt.Errorf("%s: %w", page.Source, err)
+		}
+		for name, entry := range pageEntries {
+			entries[name] = append(entries[name], entry)
+		}
+	}
+
+	s.Menus = Menus{}
+	for name, list := range entries {
+		menu, err := newMenu(list)
+		if err != nil {
+			return fmt.Errorf("menu %s: %w", name, err)
+		}
+		s.Menus[name] = menu
+	}
+	return nil
+}
+
+// pageMenus reads the menu front matter of a page, written as a menu name, a
+// list of names or a map of names to the entry fields: name, identifier,
+// weight and parent.
+func pageMenus(page *Page) (map[string]*MenuEntry, error) {
+	out := map[string]*MenuEntry{}
+	switch value := page.Params["menu"].(type) {
+	case nil:
+		return out, nil
+	case string, []interface{}:
+		for _, name := range listParam(page.Params, "menu") {
+			out[name] = &MenuEntry{Page: page}
+		}
+	case map[string]interface{}:
+		for name, fields := range value {
+			entry := &MenuEntry{Page: page}
+			switch fields := fields.(type) {
+			case nil:
+			case map[string]interface{}:
+				params := map[string]interface{}{}
+				for key, field := range fields {
+					params[strings.ToLower(key)] = field
+				}
+				entry.Name = stringParam(params, "name")
+				entry.Identifier = stringParam(params, "identifier")
+				entry.Parent = stringParam(params, "parent")
+				if weight, ok := toFloat(params["weight"]); ok {
+					entry.Weight = int(weight)
+				}
+			default:
+				return nil, fmt.Errorf("menu %s: expected a map of entry fields", name)
+			}
+			out[name] = entry
+		}
+	default:
+		return nil, fmt.Errorf("menu: expected a name, a list of names or a map")
+	}
+	return out, nil
+}
+
+// newMenu fills the defaults of the entries, nests them under their parents
+// and orders every level.
+func newMenu(entries []*MenuEntry) (Menu, error) {
+	byID := map[string]*MenuEntry{}
+	for _, entry := range entries {
+		if entry.Page != nil {
+			entry.URL = "/" + entry.Page.Permalink
+			if entry.Name == "" {
+				entry.Name = entry.Page.Title
+			}
+			if entry.Weight == 0 {
+				entry.Weight = entry.Page.Weight
+			}
+		}
+		if entry.Identifier == "" {
+			entry.Identifier = entry.Name
+		}
+		if _, ok := byID[entry.Identifier]; ok {
+			return nil, fmt.Errorf("entry %q is defined more than once, set an identifier to tell them apart", entry.Identifier)
+		}
+		byID[entry.Identifier] = entry
+	}
+
+	menu := Menu{}
+	for _, entry := range entries {
+		if entry.Parent == "" {
+			menu = append(menu, entry)
+			continue
+		}
+		parent, ok := byID[entry.Parent]
+		if !ok {
+			return nil, fmt.Errorf("entry %q has an unknown parent %q", entry.Identifier, entry.Parent)
+		}
+		ancestor := parent
+		for depth := 0; ancestor != nil && ancestor != entry && depth < len(entries); depth++ {
+			ancestor = byID[ancestor.Parent]
+		}
+		if ancestor != nil {
+			return nil, fmt.Errorf("entry %q is its own ancestor", entry.Identifier)
+		}
+		parent.Children = append(parent.Children, entry)
+	}
+
+	for _, entry := range entries {
+		entry.Children.sort()
+	}
+	menu.sort()
+	return menu, nil
+}
+
+func (m Menu) sort() {
+	sort.SliceStable(m, func(i, j int) bool {
+		return byWeight(m[i].Weight, m[j].Weight, m[i].Name, m[j].Name)
+	})
+}
@@ -37,8 +37,13 @@ func (m MinifyPlugin) AllowedMediaType(filename string) string {
 	return m.mediaTypes[filepath.Ext(filename)]
 }
 
-func (m MinifyPlugin) AfterGeneratedFiles(files []string) error {
+func (m MinifyPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "minify", Version: builtinVersion}
+}
+
+func (m MinifyPlugin) AfterGeneratedFiles(site *Site, files []string) error {
 	m.logger.Info("Start minifying")
+	var errs errorCollector
 	wp := workerpool.New(2)
 	maxJobs := 0
 	for _, f := range files {
@@ -48,21 +53,18 @@ func (m MinifyPlugin) AfterGeneratedFiles(files []string) error {
 			continue
 		}
 		wp.Submit(func() {
-			if err := m.minifyFiles(f, media); err != nil {
-				m.logger.Error(err.Error())
-
-				maxJobs += 1
-				if maxJobs == 8 {
-					maxJobs = 0
-					wp.StopWait()
-					wp = workerpool.New(2)
-				}
-			}
+			errs.add(m.minifyFiles(f, media))
 		})
+		maxJobs += 1
+		if maxJobs == 8 {
+			maxJobs = 0
+			wp.StopWait()
+			wp = workerpool.New(2)
+		}
 	}
 	wp.StopWait()
 	m.logger.Info("Finished minifying")
-	return nil
+	return errs.err()
 }
 
 func (m MinifyPlugin) minifyFiles(f, media string) error {
@@ -31,7 +31,7 @@ func (w Windows) ShouldGenerate(op string) bool {
 }
 
 func (w Windows) WriteFlags() int {
-	return os.O_CREATE
+	return os.O_CREATE | os.O_TRUNC
 }
 
 type Linux struct{}
@@ -41,7 +41,7 @@ func (l Linux) ShouldGenerate(op string) bool {
 }
 
 func (l Linux) WriteFlags() int {
-	return os.O_WRONLY | os.O_CREATE
+	return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
 }
 
 type MaCOSX struct {}
@@ -51,5 +51,5 @@ func (m MaCOSX) ShouldGenerate(op string) bool{
 }
 
 func (m MaCOSX) WriteFlags() int {
-	return os.O_WRONLY | os.O_CREATE
+	return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
 }
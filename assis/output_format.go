@@ -0,0 +1,170 @@
+package assis
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OutputFormat is a kind of file pages are rendered to. Plain text formats
+// are rendered with text/template, so nothing in them is HTML escaped.
+type OutputFormat struct {
+	Name      string `json:"name"`
+	MediaType string `json:"media_type"`
+	Extension string `json:"extension"`
+	PlainText bool   `json:"plain_text"`
+}
+
+type OutputFormats []OutputFormat
+
+// DefaultOutputFormats are the formats every site knows. The output_formats
+// of the config add to them or replace them by name.
+var DefaultOutputFormats = OutputFormats{
+	{Name: "html", MediaType: "text/html", Extension: ".html"},
+	{Name: "amp", MediaType: "text/html", Extension: ".html"},
+	{Name: "json", MediaType: "application/json", Extension: ".json", PlainText: true},
+	{Name: "xml", MediaType: "application/xml", Extension: ".xml", PlainText: true},
+	{Name: "rss", MediaType: "application/rss+xml", Extension: ".xml", PlainText: true},
+	{Name: "text", MediaType: "text/plain", Extension: ".txt", PlainText: true},
+	{Name: "webmanifest", MediaType: "application/manifest+json", Extension: ".webmanifest", PlainText: true},
+}
+
+// AllOutputFormats returns the default formats with the ones of the config
+// applied.
+func (c *Config) AllOutputFormats() OutputFormats {
+	formats := append(OutputFormats{}, DefaultOutputFormats...)
+	for _, format := range c.OutputFormats {
+		if i := formats.index(format.Name); i >= 0 {
+			formats[i] = format
+			continue
+		}
+		formats = append(formats, format)
+	}
+	return formats
+}
+
+func (f OutputFormats) index(name string) int {
+	for i, format := range f {
+		if strings.EqualFold(format.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f OutputFormats) ByName(name string) (OutputFormat, bool) {
+	if i := f.index(name); i >= 0 {
+		return f[i], true
+	}
+	return OutputFormat{}, false
+}
+
+// ByExtension returns the first format writing files with ext, e.g. ".xml".
+func (f OutputFormats) ByExtension(ext string) (OutputFormat, bool) {
+	for _, format := range f {
+		if format.Extension == ext {
+			return format, true
+		}
+	}
+	return OutputFormat{}, false
+}
+
+// formatOf finds the format of a page: the one its front matter names, else
+// the one of its layout extension, else the first of outputs, HTML by
+// default.
+func formatOf(page *Page, formats OutputFormats, outputs []string) (OutputFormat, error) {
+	if name := stringParam(page.Params, "format"); name != "" {
+		return formats.named(page, name)
+	}
+	if format, ok := formats.ByExtension(filepath.Ext(page.Layout)); ok && page.Layout != "" {
+		return format, nil
+	}
+	if len(outputs) > 0 {
+		return formats.named(page, outputs[0])
+	}
+	format, _ := formats.ByName("html")
+	return format, nil
+}
+
+func (f OutputFormats) named(page *Page, name string) (OutputFormat, error) {
+	format, ok := f.ByName(name)
+	if !ok {
+		return OutputFormat{}, fmt.Errorf("%s: unknown output format %s", page.Source, name)
+	}
+	return format, nil
+}
+
+// setOutputs gives the page its format and one alternative output per other
+// format it lists, in its outputs front matter or, for its kind, in the
+// outputs of the config. An alternative is a copy of the page written next to
+// it with the extension of its format, e.g. title.json, or with the format
+// name too when the extension is taken, e.g. title.amp.html.
+func (p *Page) setOutputs(formats OutputFormats, outputs map[string][]string) error {
+	names := listParam(p.Params, "outputs")
+	if len(names) == 0 && stringParam(p.Params, "format") == "" {
+		names = outputs[p.Kind]
+	}
+
+	format, err := formatOf(p, formats, names)
+	if err != nil {
+		return err
+	}
+	p.setFormat(format)
+
+	p.alternatives = nil
+	extensions := map[string]bool{format.Extension: true}
+	for _, name := range names {
+		alternative, err := formats.named(p, name)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(alternative.Name, format.Name) {
+			continue
+		}
+
+		page := *p
+		page.primary, page.alternatives = p, nil
+		page.setFormat(alternative)
+		if extensions[alternative.Extension] {
+			page.Output = strings.TrimSuffix(page.Output, alternative.Extension) + "." + alternative.Name + alternative.Extension
+			page.Permalink = strings.TrimSuffix(page.Permalink, alternative.Extension) + "." + alternative.Name + alternative.Extension
+		}
+		extensions[alternative.Extension] = true
+		p.alternatives = append(p.alternatives, &page)
+	}
+	return nil
+}
+
+// setFormat gives the page its format, changing the extension of its output
+// file and permalink to the one of the format.
+func (p *Page) setFormat(format OutputFormat) {
+	p.Format = format
+	if format.Extension == "" {
+		return
+	}
+	p.Output = strings.TrimSuffix(p.Output, filepath.Ext(p.Output)) + format.Extension
+	p.Permalink = strings.TrimSuffix(p.Permalink, filepath.Ext(p.Permalink)) + format.Extension
+}
+
+// Outputs returns the page in each of its output formats, itself first.
+// Plugins render every one of them.
+func (p *Page) Outputs() []*Page {
+	return append([]*Page{p}, p.alternatives...)
+}
+
+// AlternativeOutputs returns the page in its other output formats, for
+// templates to link to, e.g. the JSON version of an HTML page.
+func (p *Page) AlternativeOutputs() []*Page {
+	root := p
+	if p.primary != nil {
+		root = p.primary
+	}
+
+	var out []*Page
+	for _, page := range root.Outputs() {
+		if page != p {
+			out = append(out, page)
+		}
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package assis
+
+import (
+	"fmt"
+	"github.com/gomarkdown/markdown"
+	"github.com/gosimple/slug"
+	"html/template"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KindPage    = "page"
+	KindArticle = "article"
+	KindData    = "data"
+	KindList    = "list"
+	KindSection = "section"
+)
+
+// SectionIndex is the name, without extension, of the files describing the
+// section of their directory.
+const SectionIndex = "_index"
+
+// Page is one page of the site, whatever plugin renders it. Params holds the
+// front matter; RawContent is the body as written and Content the rendered
+// body, when the kind has one. Inherited lists the front matter keys taken
+// from a cascade, with the file that set them. Format is the output format
+// the page is rendered to, HTML unless its front matter or layout says
+// otherwise, and AlternativeOutputs the same page in the other formats it is
+// rendered to.
+type Page struct {
+	Kind        string
+	ID          string
+	Source      string
+	Output      string
+	Permalink   string
+	SectionPath string
+	Section     *Section `json:"-"`
+	Title       string
+	Description string
+	Date        string
+	Layout      string
+	Format      OutputFormat
+	Weight      int
+	Params      map[string]interface{}
+	RawContent  string
+	Content     template.HTML
+	Inherited   map[string]string
+	Pages       []*Page
+	Sections    []*Section
+	Site        *Site `json:"-"`
+
+	file         File
+	rel          string
+	outputFile   string
+	primary      *Page
+	alternatives []*Page
+}
+
+// newContentPage reads a file of the content folder into a page. HTML files
+// keep their body as template source, with front matter replaced by blank
+// lines so template errors keep pointing at the right line. The fields coming
+// from the front matter are only set by resolve, once cascades are applied.
+func newContentPage(config *Config, container *FileContainer, file File) (*Page, error) {
+	source := filepath.ToSlash(container.FullFilename(file))
+	b, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(config.Content, container.entry)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	ext := filepath.Ext(string(file))
+	split := splitHeaders
+	if ext == HTML {
+		split = splitFrontMatter
+	}
+	params, body, lines, err := split(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	page := &Page{
+		Source:      source,
+		SectionPath: sectionPath(rel),
+		Params:      params,
+		Inherited:   map[string]string{},
+		file:        file,
+		rel:         rel,
+		outputFile:  container.OutputFilename(file),
+	}
+	if ext == HTML {
+		page.RawContent = strings.Repeat("\n", lines) + string(body)
+	} else {
+		page.RawContent = string(body)
+		page.Content = template.HTML(markdown.ToHTML(body, nil, nil))
+	}
+
+	switch {
+	case strings.TrimSuffix(string(file), ext) == SectionIndex:
+		page.Kind = KindSection
+	case ext == HTML:
+		page.Kind = KindPage
+	case ext == MD:
+		page.Kind = KindArticle
+	default:
+		return nil, fmt.Errorf("%s: not a content page", source)
+	}
+	return page, nil
+}
+
+// resolve sets the fields taken from the front matter. Markdown articles are
+// published under the slug of their title, _index files as the index.html of
+// their section.
+func (p *Page) resolve() {
+	switch p.Kind {
+	case KindSection:
+		p.ID = sectionTitle(p.SectionPath)
+		p.Permalink = path.Join(p.rel, "index.html")
+		p.Output = strings.Replace(p.outputFile, string(p.file), "index.html", 1)
+	case KindPage:
+		p.ID = strings.TrimSuffix(string(p.file), HTML)
+		p.Permalink = path.Join(p.rel, string(p.file))
+		p.Output = p.outputFile
+	case KindArticle:
+		p.ID = slug.Make(stringParam(p.Params, "title"))
+		p.Permalink = path.Join(p.rel, p.ID+".html")
+		p.Output = strings.Replace(p.outputFile, string(p.file), p.ID+".html", 1)
+	}
+
+	p.Title = stringParam(p.Params, "title")
+	if p.Title == "" && p.Kind != KindSection {
+		p.Title = humanize(p.ID)
+	}
+	p.Description = stringParam(p.Params, "description")
+	p.Date = stringParam(p.Params, "date")
+	p.Layout = stringParam(p.Params, "layout")
+	if p.Layout == "" {
+		p.Layout = stringParam(p.Params, "template")
+	}
+	if weight, ok := toFloat(p.Params["weight"]); ok {
+		p.Weight = int(weight)
+	}
+}
+
+// sectionPath turns a directory relative to the content folder into the form
+// used by the collection functions, e.g. "articles/posts" -> "/articles/posts".
+func sectionPath(relative string) string {
+	relative = strings.Trim(filepath.ToSlash(relative), "/")
+	if relative == "" || relative == "." {
+		return "/"
+	}
+	return "/" + relative
+}
@@ -0,0 +1,160 @@
+package assis
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// partialRenderer backs the partial and partialCached template functions of
+// one build. Partials run in a set of their own, a clone of the base set
+// parsed once per build, so they only see the context they are called with.
+// Partials with the extension of a plain text format run with text/template
+// and return a plain string, which html/template escapes.
+type partialRenderer struct {
+	templates Templates
+	assis     AssisTemplate
+
+	once sync.Once
+	set  *template.Template
+	err  error
+
+	mu       sync.Mutex
+	textSets map[string]*texttemplate.Template
+	cached   map[string]*cachedPartial
+}
+
+// cachedPartial is the result of a partialCached key, rendered once even when
+// pages ask for it at the same time.
+type cachedPartial struct {
+	once sync.Once
+	out  interface{}
+	err  error
+}
+
+func newPartialRenderer(templates Templates, assis AssisTemplate) *partialRenderer {
+	return &partialRenderer{
+		templates: templates,
+		assis:     assis,
+		textSets:  map[string]*texttemplate.Template{},
+		cached:    map[string]*cachedPartial{},
+	}
+}
+
+// partial renders a partial with an explicit context: partial "sidebar.html"
+// (dict "pages" .Pages). The name is the path of the partial file in the
+// partials folder, with or without .html, or a template it defines.
+func (p *partialRenderer) partial(name string, context ...interface{}) (interface{}, error) {
+	if len(context) > 1 {
+		return "", fmt.Errorf("partial %s: expected at most one context", name)
+	}
+	var data interface{}
+	if len(context) == 1 {
+		data = context[0]
+	}
+
+	file := p.file(name)
+	if ext := filepath.Ext(file); ext != "" && ext != HTML {
+		return p.renderText(name, file, data)
+	}
+
+	p.once.Do(p.parse)
+	if p.err != nil {
+		return "", p.err
+	}
+	tplName := name
+	if file != "" {
+		tplName = templateName(file)
+	}
+	tpl := p.set.Lookup(tplName)
+	if tpl == nil {
+		return "", fmt.Errorf("partial %s not found", name)
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return template.HTML(out.String()), nil
+}
+
+// partialCached renders a partial once per build for each distinct name and
+// keys, and reuses the result: partialCached "recent.html" . .Section. With
+// no keys the partial is rendered once, with the first context it gets.
+func (p *partialRenderer) partialCached(name string, context interface{}, keys ...interface{}) (interface{}, error) {
+	key := name
+	for _, k := range keys {
+		key += "\x00" + fmt.Sprint(k)
+	}
+
+	p.mu.Lock()
+	cached, ok := p.cached[key]
+	if !ok {
+		cached = &cachedPartial{}
+		p.cached[key] = cached
+	}
+	p.mu.Unlock()
+
+	cached.once.Do(func() {
+		cached.out, cached.err = p.partial(name, context)
+	})
+	return cached.out, cached.err
+}
+
+// file returns the partial file a name stands for, "" when it names a
+// template defined inside a partial.
+func (p *partialRenderer) file(name string) string {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+	for _, rel := range []string{name, name + HTML} {
+		if i, ok := p.templates.partialIndex[rel]; ok {
+			return p.templates.partials[i]
+		}
+	}
+	return ""
+}
+
+func (p *partialRenderer) parse() {
+	cache := p.templates.cache
+	if cache == nil {
+		cache = NewTemplateCache()
+	}
+	p.set, p.err = cache.clone(p.templates.baseOrdered, p.assis.GetTemplate)
+	if p.err == nil {
+		p.set.Funcs(p.assis.funcMap).Option(p.assis.missingKey())
+	}
+}
+
+// renderText renders a partial of a plain text format, parsing the partials
+// of its extension the first time one of them is called.
+func (p *partialRenderer) renderText(name, file string, data interface{}) (string, error) {
+	ext := filepath.Ext(file)
+
+	p.mu.Lock()
+	set, ok := p.textSets[ext]
+	if !ok {
+		set = texttemplate.New(name).Funcs(texttemplate.FuncMap(p.assis.funcMap)).Option(p.assis.missingKey())
+		for _, partial := range p.templates.Partials(ext) {
+			b, err := ioutil.ReadFile(partial)
+			if err == nil {
+				_, err = set.New(templateName(partial)).Parse(string(b))
+			}
+			if err != nil {
+				p.mu.Unlock()
+				return "", err
+			}
+		}
+		p.textSets[ext] = set
+	}
+	p.mu.Unlock()
+
+	var out bytes.Buffer
+	if err := set.ExecuteTemplate(&out, templateName(file), data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
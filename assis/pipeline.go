@@ -0,0 +1,176 @@
+package assis
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// PluginConfig is an entry of the plugins section of the config. Listing a
+// plugin orders it before the ones not listed, in the order of the list;
+// Enabled false turns it off. Options are checked against the schema of the
+// plugin. Plugins left out of the section keep running with their defaults.
+type PluginConfig struct {
+	Name    string                 `json:"name"`
+	Enabled *bool                  `json:"enabled"`
+	Options map[string]interface{} `json:"options"`
+}
+
+func (p PluginConfig) enabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// pluginOrder returns the names of the plugins the plugins section enables, in
+// its order.
+func (c *Config) pluginOrder() []string {
+	var names []string
+	for _, plugin := range c.Plugins {
+		if plugin.enabled() {
+			names = append(names, plugin.Name)
+		}
+	}
+	return names
+}
+
+// pluginDisabled tells whether the plugins section turns a plugin off.
+func (c *Config) pluginDisabled(name string) bool {
+	for _, plugin := range c.Plugins {
+		if plugin.Name == name {
+			return !plugin.enabled()
+		}
+	}
+	return false
+}
+
+// PluginFactory makes a plugin the plugins section of the config can refer to
+// by name, with its options checked against the Options schema.
+type PluginFactory struct {
+	Name    string
+	Options []PluginOption
+	New     func(config *Config, options PluginOptions, logger *zap.Logger) (interface{}, error)
+}
+
+// BuiltinPlugins returns the factories of the plugins shipped with assis,
+// which run unless the config turns them off. The functions plugin isn't one
+// of them: templates always get the built-in functions.
+func BuiltinPlugins() []PluginFactory {
+	return []PluginFactory{
+		{Name: "articles", New: func(config *Config, _ PluginOptions, logger *zap.Logger) (interface{}, error) {
+			return NewArticlePlugin(config, logger), nil
+		}},
+		{Name: "data_pages", New: func(config *Config, _ PluginOptions, logger *zap.Logger) (interface{}, error) {
+			return NewDataPagePlugin(config, logger), nil
+		}},
+		{Name: "html", New: func(config *Config, _ PluginOptions, logger *zap.Logger) (interface{}, error) {
+			return NewHTMLPlugin(config, logger), nil
+		}},
+		{Name: "sections", New: func(config *Config, _ PluginOptions, logger *zap.Logger) (interface{}, error) {
+			return NewSectionPlugin(config, logger), nil
+		}},
+		{Name: "collections", New: func(*Config, PluginOptions, *zap.Logger) (interface{}, error) {
+			return NewCollectionPlugin(), nil
+		}},
+		{
+			Name: "static_files",
+			Options: []PluginOption{{
+				Name:        "extensions",
+				Type:        OptionStrings,
+				Default:     []string{".svg", ".js", ".png", ".jpg", ".jpeg", ".gif", ".css"},
+				Description: "extensions of the content files copied to the output",
+			}},
+			New: func(config *Config, options PluginOptions, logger *zap.Logger) (interface{}, error) {
+				return NewStaticFilesPlugin(config, options.Strings("extensions"), logger), nil
+			},
+		},
+		{
+			Name: "minify",
+			Options: []PluginOption{{
+				Name:        "extensions",
+				Type:        OptionStrings,
+				Default:     []string{".html", ".css", ".js"},
+				Description: "extensions of the generated files minified, among .html, .css and .js",
+			}},
+			New: func(config *Config, options PluginOptions, logger *zap.Logger) (interface{}, error) {
+				minify := NewMinifyPlugin(logger)
+				mediaTypes := map[string]string{}
+				for _, ext := range options.Strings("extensions") {
+					mediaType, ok := minify.mediaTypes[ext]
+					if !ok {
+						return nil, fmt.Errorf("can't minify %s files", ext)
+					}
+					mediaTypes[ext] = mediaType
+				}
+				minify.mediaTypes = mediaTypes
+				return minify, nil
+			},
+		},
+	}
+}
+
+// Pipeline is the plugins of a build, as the plugins section of the config
+// sets them up, with the external plugins launched.
+type Pipeline struct {
+	Plugins  []interface{}
+	external ExternalPlugins
+}
+
+// NewPipeline makes the plugins of the built-in factories and of the custom
+// ones the config doesn't turn off, then launches the external plugins.
+// Entries of the plugins section must name one of the factories or an
+// external plugin, which takes its options in external_plugins.
+func NewPipeline(config *Config, logger *zap.Logger, custom ...PluginFactory) (*Pipeline, error) {
+	factories := append(BuiltinPlugins(), custom...)
+	known := map[string]bool{}
+	for _, factory := range factories {
+		if known[factory.Name] {
+			return nil, fmt.Errorf("plugin %s is registered twice", factory.Name)
+		}
+		known[factory.Name] = true
+	}
+
+	external := map[string]bool{}
+	for _, plugin := range config.ExternalPlugins {
+		external[plugin.Name] = plugin.Name != ""
+	}
+	entries := map[string]PluginConfig{}
+	for _, entry := range config.Plugins {
+		if !known[entry.Name] && !external[entry.Name] {
+			return nil, fmt.Errorf("unknown plugin %s in plugins", entry.Name)
+		}
+		if external[entry.Name] && len(entry.Options) > 0 {
+			return nil, fmt.Errorf("plugin %s is external: set its options in external_plugins", entry.Name)
+		}
+		entries[entry.Name] = entry
+	}
+
+	pipeline := &Pipeline{}
+	for _, factory := range factories {
+		entry, listed := entries[factory.Name]
+		if listed && !entry.enabled() {
+			logger.Info(fmt.Sprintf("Plugin %s is disabled", factory.Name))
+			continue
+		}
+		options, err := checkPluginOptions(factory.Options, entry.Options)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", factory.Name, err)
+		}
+		plugin, err := factory.New(config, options, logger)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", factory.Name, err)
+		}
+		pipeline.Plugins = append(pipeline.Plugins, plugin)
+	}
+
+	launched, err := LaunchExternalPlugins(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.external = launched
+	pipeline.Plugins = append(pipeline.Plugins, launched.Plugins()...)
+	return pipeline, nil
+}
+
+// Close shuts the external plugins down.
+func (p *Pipeline) Close() error {
+	return p.external.Close()
+}
@@ -0,0 +1,122 @@
+package assis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewPipeline(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	disabled := false
+
+	t.Run("run the built-in plugins by default", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		pipeline, err := NewPipeline(cfg, logger)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"articles", "data_pages", "html", "sections", "collections", "static_files", "minify"}, names(pipeline.Plugins))
+		assert.Equal(t, []string{".svg", ".js", ".png", ".jpg", ".jpeg", ".gif", ".css"}, pipeline.Plugins[5].(StaticFilesPlugin).allowedExt)
+		assert.NoError(t, pipeline.Close())
+	})
+
+	t.Run("disable, order and configure plugins", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Plugins = []PluginConfig{
+			{Name: "minify", Enabled: &disabled},
+			{Name: "sections"},
+			{Name: "static_files", Options: map[string]interface{}{"extensions": []interface{}{".css"}}},
+		}
+		pipeline, err := NewPipeline(cfg, logger)
+		assert.NoError(t, err)
+		assert.NotContains(t, names(pipeline.Plugins), "minify")
+		assert.Equal(t, []string{".css"}, pipeline.Plugins[5].(StaticFilesPlugin).allowedExt)
+
+		site := NewAssis(cfg, pipeline.Plugins, logger)
+		ordered, err := site.orderedPlugins(HookLoadFiles)
+		assert.NoError(t, err)
+		// sections still runs after articles
+		assert.Equal(t, []string{"static_files", "articles", "sections", "collections", "data_pages", "html"}, names(ordered))
+	})
+
+	t.Run("add custom plugins", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Plugins = []PluginConfig{{Name: "custom", Options: map[string]interface{}{"weight": float64(3)}}}
+		custom := PluginFactory{
+			Name:    "custom",
+			Options: []PluginOption{{Name: "weight", Type: OptionInt, Required: true}},
+			New: func(config *Config, options PluginOptions, logger *zap.Logger) (interface{}, error) {
+				return testPlugin{PluginInfo{Name: "custom", Priority: map[string]int{HookRender: options.Int("weight")}}}, nil
+			},
+		}
+		pipeline, err := NewPipeline(cfg, logger, custom)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, pipeline.Plugins[7].(PluginDescriber).Describe().Priority[HookRender])
+
+		_, err = NewPipeline(cfg, logger, custom, custom)
+		assert.EqualError(t, err, "plugin custom is registered twice")
+	})
+
+	t.Run("check the plugins section", func(t *testing.T) {
+		cfg := NewDefaultConfig("./mock/_site")
+		cfg.Plugins = []PluginConfig{{Name: "sitemap"}}
+		_, err := NewPipeline(cfg, logger)
+		assert.EqualError(t, err, "unknown plugin sitemap in plugins")
+
+		cfg.Plugins = []PluginConfig{{Name: "html", Options: map[string]interface{}{"pretty": true}}}
+		_, err = NewPipeline(cfg, logger)
+		assert.EqualError(t, err, "plugin html: unknown option pretty")
+
+		cfg.Plugins = []PluginConfig{{Name: "minify", Options: map[string]interface{}{"extensions": ".css"}}}
+		_, err = NewPipeline(cfg, logger)
+		assert.EqualError(t, err, "plugin minify: option extensions must be of type strings")
+
+		cfg.Plugins = []PluginConfig{{Name: "minify", Options: map[string]interface{}{"extensions": []interface{}{".svg"}}}}
+		_, err = NewPipeline(cfg, logger)
+		assert.EqualError(t, err, "plugin minify: can't minify .svg files")
+
+		cfg.Plugins = []PluginConfig{{Name: "readingtime", Enabled: &disabled}}
+		cfg.ExternalPlugins = []ExternalPluginConfig{{Name: "readingtime", Command: "./plugins/missing"}}
+		pipeline, err := NewPipeline(cfg, logger)
+		assert.NoError(t, err)
+		assert.Len(t, pipeline.Plugins, 7)
+
+		assert.EqualError(t, checkConfigPlugins([]PluginConfig{{Name: "html"}, {Name: "html"}}),
+			"plugin html is listed twice in the plugins of your config.json")
+	})
+}
+
+func TestCheckPluginOptions(t *testing.T) {
+	schema := []PluginOption{
+		{Name: "title", Type: OptionString, Default: "untitled"},
+		{Name: "draft", Type: OptionBool},
+		{Name: "count", Type: OptionInt, Default: 2},
+		{Name: "ratio", Type: OptionNumber},
+		{Name: "tags", Type: OptionStrings},
+		{Name: "extra", Type: OptionObject},
+	}
+
+	options, err := checkPluginOptions(schema, map[string]interface{}{
+		"draft": true,
+		"ratio": float64(1),
+		"tags":  []interface{}{"a", "b"},
+		"extra": map[string]interface{}{"key": "value"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "untitled", options.String("title"))
+	assert.Equal(t, true, options.Bool("draft"))
+	assert.Equal(t, 2, options.Int("count"))
+	assert.Equal(t, 1.0, options.Number("ratio"))
+	assert.Equal(t, []string{"a", "b"}, options.Strings("tags"))
+	assert.Equal(t, "value", options.Object("extra")["key"])
+
+	_, err = checkPluginOptions(schema, map[string]interface{}{"count": 1.5})
+	assert.EqualError(t, err, "option count must be of type int")
+
+	_, err = checkPluginOptions(schema, map[string]interface{}{"tags": []interface{}{"a", 1.0}})
+	assert.EqualError(t, err, "option tags must be of type strings")
+
+	_, err = checkPluginOptions([]PluginOption{{Name: "key", Type: OptionString, Required: true}}, nil)
+	assert.EqualError(t, err, "option key is required")
+}
@@ -0,0 +1,146 @@
+package assis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Types of plugin options.
+const (
+	OptionString  = "string"
+	OptionBool    = "bool"
+	OptionInt     = "int"
+	OptionNumber  = "number"
+	OptionStrings = "strings"
+	OptionObject  = "object"
+)
+
+// PluginOption describes one option a plugin takes in the plugins section of
+// the config. Options left out get their Default, unless Required.
+type PluginOption struct {
+	Name        string
+	Type        string
+	Default     interface{}
+	Required    bool
+	Description string
+}
+
+// PluginOptions are the options of a plugin, checked against its schema, so
+// each holds the Go type of its option type: string, bool, int, float64,
+// []string or map[string]interface{}.
+type PluginOptions map[string]interface{}
+
+func (o PluginOptions) String(name string) string {
+	value, _ := o[name].(string)
+	return value
+}
+
+func (o PluginOptions) Bool(name string) bool {
+	value, _ := o[name].(bool)
+	return value
+}
+
+func (o PluginOptions) Int(name string) int {
+	value, _ := o[name].(int)
+	return value
+}
+
+func (o PluginOptions) Number(name string) float64 {
+	value, _ := o[name].(float64)
+	return value
+}
+
+func (o PluginOptions) Strings(name string) []string {
+	value, _ := o[name].([]string)
+	return value
+}
+
+func (o PluginOptions) Object(name string) map[string]interface{} {
+	value, _ := o[name].(map[string]interface{})
+	return value
+}
+
+// checkPluginOptions checks the options of the config against a schema and
+// returns them with the defaults of the missing ones.
+func checkPluginOptions(schema []PluginOption, options map[string]interface{}) (PluginOptions, error) {
+	known := map[string]bool{}
+	out := PluginOptions{}
+	for _, option := range schema {
+		known[option.Name] = true
+		value, ok := options[option.Name]
+		if !ok || value == nil {
+			if option.Required {
+				return nil, fmt.Errorf("option %s is required", option.Name)
+			}
+			value = option.Default
+			if value == nil {
+				continue
+			}
+		}
+
+		typed, ok := optionValue(option.Type, value)
+		if !ok {
+			return nil, fmt.Errorf("option %s must be of type %s", option.Name, option.Type)
+		}
+		out[option.Name] = typed
+	}
+
+	var unknown []string
+	for name := range options {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown option %s", unknown[0])
+	}
+	return out, nil
+}
+
+// optionValue converts a value decoded from JSON, or a default, to the Go
+// type of an option type.
+func optionValue(typ string, value interface{}) (interface{}, bool) {
+	switch typ {
+	case OptionString:
+		value, ok := value.(string)
+		return value, ok
+	case OptionBool:
+		value, ok := value.(bool)
+		return value, ok
+	case OptionInt:
+		switch value := value.(type) {
+		case int:
+			return value, true
+		case float64:
+			return int(value), value == math.Trunc(value)
+		}
+	case OptionNumber:
+		switch value := value.(type) {
+		case int:
+			return float64(value), true
+		case float64:
+			return value, true
+		}
+	case OptionStrings:
+		switch value := value.(type) {
+		case []string:
+			return value, true
+		case []interface{}:
+			out := make([]string, len(value))
+			for i, v := range value {
+				s, ok := v.(string)
+				if !ok {
+					return nil, false
+				}
+				out[i] = s
+			}
+			return out, true
+		}
+	case OptionObject:
+		value, ok := value.(map[string]interface{})
+		return value, ok
+	}
+	return nil, false
+}
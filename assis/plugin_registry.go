@@ -0,0 +1,230 @@
+package assis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Hooks a plugin can implement, the keys of PluginInfo.Priority.
+const (
+	HookConfigLoaded   = "config_loaded"
+	HookBeforeLoad     = "before_load"
+	HookContentParsed  = "content_parsed"
+	HookLoadFiles      = "load_files"
+	HookBuildSite      = "build_site"
+	HookCustomFunction = "custom_function"
+	HookRender         = "render"
+	HookPageRendered   = "page_rendered"
+	HookGeneratedFiles = "generated_files"
+	HookAfterBuild     = "after_build"
+	HookWatchEvent     = "watch_event"
+)
+
+// builtinVersion is the version of the plugins shipped with assis.
+const builtinVersion = "1.0.0"
+
+// PluginInfo describes a plugin to the registry. Dependencies must be
+// registered and run before the plugin on every hook; After only orders the
+// plugin after the ones of its list that are registered. Among the plugins
+// free to run, the lowest Priority of the hook goes first, then the plugins
+// listed in the config, in its order, then the name.
+type PluginInfo struct {
+	Name         string
+	Version      string
+	Dependencies []string
+	After        []string
+	Priority     map[string]int
+}
+
+// PluginDescriber is implemented by plugins to give their PluginInfo.
+// Plugins that don't are named after their type, with no dependencies.
+type PluginDescriber interface {
+	Describe() PluginInfo
+}
+
+// PluginRegistry holds the plugins of a build and orders them for each hook,
+// whatever order they were registered in.
+type PluginRegistry struct {
+	plugins []registeredPlugin
+	names   map[string]int
+	order   map[string]int
+}
+
+type registeredPlugin struct {
+	info   PluginInfo
+	plugin interface{}
+}
+
+func NewPluginRegistry(plugins ...interface{}) (*PluginRegistry, error) {
+	r := &PluginRegistry{names: map[string]int{}}
+	for _, plugin := range plugins {
+		if err := r.Register(plugin); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a plugin. Two plugins can't share a name, except the ones
+// named after their type, which get a number from the second on.
+func (r *PluginRegistry) Register(plugin interface{}) error {
+	var info PluginInfo
+	if describer, ok := plugin.(PluginDescriber); ok {
+		info = describer.Describe()
+	}
+
+	if info.Name == "" {
+		name := strings.TrimPrefix(reflect.TypeOf(plugin).String(), "*")
+		info.Name = name
+		for i := 2; r.Has(info.Name); i++ {
+			info.Name = fmt.Sprintf("%s#%d", name, i)
+		}
+	}
+	if r.Has(info.Name) {
+		return fmt.Errorf("plugin %s is registered twice", info.Name)
+	}
+
+	r.names[info.Name] = len(r.plugins)
+	r.plugins = append(r.plugins, registeredPlugin{info: info, plugin: plugin})
+	return nil
+}
+
+func (r *PluginRegistry) Has(name string) bool {
+	_, ok := r.names[name]
+	return ok
+}
+
+// setOrder makes the plugins named run before the others of their priority,
+// in the order given.
+func (r *PluginRegistry) setOrder(names []string) {
+	r.order = map[string]int{}
+	for i, name := range names {
+		r.order[name] = i + 1
+	}
+}
+
+// rank is the place of a plugin in the order set; the plugins not in it come
+// last.
+func (r *PluginRegistry) rank(name string) int {
+	if rank, ok := r.order[name]; ok {
+		return rank
+	}
+	return len(r.order) + 1
+}
+
+// Info returns the PluginInfo of every plugin, in registration order.
+func (r *PluginRegistry) Info() []PluginInfo {
+	out := make([]PluginInfo, len(r.plugins))
+	for i, p := range r.plugins {
+		out[i] = p.info
+	}
+	return out
+}
+
+// Validate reports unknown dependencies and dependency cycles.
+func (r *PluginRegistry) Validate() error {
+	_, err := r.Ordered("")
+	return err
+}
+
+// Ordered returns every plugin in the order of a hook: each one after its
+// dependencies, the ones free to run by priority for the hook, then in the
+// order set, then by name.
+// Callers pick the plugins implementing the hook.
+func (r *PluginRegistry) Ordered(hook string) ([]interface{}, error) {
+	before := make([][]int, len(r.plugins))
+	blocked := make([]int, len(r.plugins))
+	for i, p := range r.plugins {
+		for _, dep := range p.info.Dependencies {
+			j, ok := r.names[dep]
+			if !ok {
+				return nil, fmt.Errorf("plugin %s depends on unknown plugin %s", p.info.Name, dep)
+			}
+			before[j] = append(before[j], i)
+			blocked[i]++
+		}
+		for _, dep := range p.info.After {
+			if j, ok := r.names[dep]; ok {
+				before[j] = append(before[j], i)
+				blocked[i]++
+			}
+		}
+	}
+
+	var ready []int
+	for i := range r.plugins {
+		if blocked[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	var out []interface{}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(a, b int) bool {
+			pa, pb := r.plugins[ready[a]].info, r.plugins[ready[b]].info
+			if pa.Priority[hook] != pb.Priority[hook] {
+				return pa.Priority[hook] < pb.Priority[hook]
+			}
+			if ra, rb := r.rank(pa.Name), r.rank(pb.Name); ra != rb {
+				return ra < rb
+			}
+			return pa.Name < pb.Name
+		})
+		next := ready[0]
+		ready = ready[1:]
+		out = append(out, r.plugins[next].plugin)
+
+		for _, i := range before[next] {
+			blocked[i]--
+			if blocked[i] == 0 {
+				ready = append(ready, i)
+			}
+		}
+	}
+
+	if len(out) < len(r.plugins) {
+		return nil, fmt.Errorf("plugin dependency cycle: %s", r.cycle(blocked, before))
+	}
+	return out, nil
+}
+
+// cycle describes one dependency cycle among the plugins left blocked, e.g.
+// "a -> b -> a", each plugin running before the next. Every blocked plugin
+// waits on another blocked one, so walking back through them ends in a cycle.
+func (r *PluginRegistry) cycle(blocked []int, before [][]int) string {
+	waitsOn := make([][]int, len(r.plugins))
+	start := -1
+	for i := range r.plugins {
+		for _, next := range before[i] {
+			waitsOn[next] = append(waitsOn[next], i)
+		}
+		if start < 0 && blocked[i] > 0 {
+			start = i
+		}
+	}
+
+	seen := map[int]int{}
+	var path []int
+	for i := start; ; {
+		if at, ok := seen[i]; ok {
+			path = append(path[at:], i)
+			break
+		}
+		seen[i] = len(path)
+		path = append(path, i)
+		for _, prev := range waitsOn[i] {
+			if blocked[prev] > 0 {
+				i = prev
+				break
+			}
+		}
+	}
+
+	names := make([]string, len(path))
+	for i, p := range path {
+		names[len(path)-1-i] = r.plugins[p].info.Name
+	}
+	return strings.Join(names, " -> ")
+}
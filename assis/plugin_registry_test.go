@@ -0,0 +1,97 @@
+package assis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"testing"
+)
+
+type testPlugin struct {
+	info PluginInfo
+}
+
+func (p testPlugin) Describe() PluginInfo {
+	return p.info
+}
+
+func names(plugins []interface{}) []string {
+	var out []string
+	for _, plugin := range plugins {
+		switch plugin := plugin.(type) {
+		case PluginDescriber:
+			out = append(out, plugin.Describe().Name)
+		}
+	}
+	return out
+}
+
+func TestPluginRegistry(t *testing.T) {
+	t.Run("orders by dependencies, then priority, then name", func(t *testing.T) {
+		plugins := []interface{}{
+			testPlugin{PluginInfo{Name: "html", After: []string{"articles"}}},
+			testPlugin{PluginInfo{Name: "minify", Dependencies: []string{"html"}}},
+			testPlugin{PluginInfo{Name: "articles", Priority: map[string]int{HookRender: 5}}},
+			testPlugin{PluginInfo{Name: "data", Priority: map[string]int{HookRender: -1}}},
+		}
+		registry, err := NewPluginRegistry(plugins...)
+		assert.NoError(t, err)
+		ordered, err := registry.Ordered(HookRender)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data", "articles", "html", "minify"}, names(ordered))
+
+		reversed, err := NewPluginRegistry(plugins[3], plugins[2], plugins[1], plugins[0])
+		assert.NoError(t, err)
+		ordered, err = reversed.Ordered(HookRender)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"data", "articles", "html", "minify"}, names(ordered))
+
+		ordered, err = registry.Ordered(HookLoadFiles)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"articles", "data", "html", "minify"}, names(ordered))
+	})
+
+	t.Run("after ignores plugins that aren't registered", func(t *testing.T) {
+		registry, err := NewPluginRegistry(testPlugin{PluginInfo{Name: "html", After: []string{"articles"}}})
+		assert.NoError(t, err)
+		assert.NoError(t, registry.Validate())
+	})
+
+	t.Run("reports unknown dependencies and cycles", func(t *testing.T) {
+		registry, _ := NewPluginRegistry(testPlugin{PluginInfo{Name: "html", Dependencies: []string{"articles"}}})
+		assert.EqualError(t, registry.Validate(), "plugin html depends on unknown plugin articles")
+
+		registry, _ = NewPluginRegistry(
+			testPlugin{PluginInfo{Name: "a", Dependencies: []string{"c"}}},
+			testPlugin{PluginInfo{Name: "b", Dependencies: []string{"a"}}},
+			testPlugin{PluginInfo{Name: "c", After: []string{"b"}}},
+			testPlugin{PluginInfo{Name: "d", Dependencies: []string{"a"}}},
+		)
+		assert.EqualError(t, registry.Validate(), "plugin dependency cycle: a -> b -> c -> a")
+
+		gen := NewGenerator(Templates{}, []interface{}{testPlugin{PluginInfo{Name: "a", Dependencies: []string{"a"}}}})
+		assert.Error(t, gen.Render(nil, nil))
+	})
+
+	t.Run("names plugins without info after their type", func(t *testing.T) {
+		registry, err := NewPluginRegistry(struct{}{}, struct{}{})
+		assert.NoError(t, err)
+		assert.Equal(t, "struct {}", registry.Info()[0].Name)
+		assert.Equal(t, "struct {}#2", registry.Info()[1].Name)
+
+		_, err = NewPluginRegistry(testPlugin{PluginInfo{Name: "a"}}, testPlugin{PluginInfo{Name: "a"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("built-in plugins render after the articles", func(t *testing.T) {
+		logger := zaptest.NewLogger(t)
+		cfg := NewDefaultConfig("./mock/_site")
+		registry, err := NewPluginRegistry(NewHTMLPlugin(cfg, logger), NewSectionPlugin(cfg, logger), NewArticlePlugin(cfg, logger))
+		assert.NoError(t, err)
+		ordered, err := registry.Ordered(HookRender)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"articles", "html", "sections"}, names(ordered))
+
+		site := NewAssis(cfg, []interface{}{NewHTMLPlugin(cfg, logger), NewHTMLPlugin(cfg, logger)}, logger)
+		assert.EqualError(t, site.LoadFilesAsync(), "plugin html is registered twice")
+	})
+}
@@ -0,0 +1,63 @@
+package assis
+
+import (
+	"fmt"
+	"github.com/gammazero/workerpool"
+	"go.uber.org/zap"
+	"path/filepath"
+)
+
+// SectionPlugin renders the list page of every section with an _index file.
+// The page is rendered with its child pages in .Pages, ordered as the index
+// asks, and its subsections in .Sections. Markdown index files need a list
+// template, HTML ones carry their own.
+type SectionPlugin struct {
+	config *Config
+	logger *zap.Logger
+}
+
+func NewSectionPlugin(config *Config, logger *zap.Logger) SectionPlugin {
+	return SectionPlugin{config: config, logger: logger}
+}
+
+func (s SectionPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "sections", Version: builtinVersion, After: []string{"articles"}}
+}
+
+func (s SectionPlugin) OnRender(t AssisTemplate, site *Site, siteFiles SiteFiles, templates Templates) error {
+	s.logger.Info("Start section rendering")
+	var errs errorCollector
+	wp := workerpool.New(2)
+	for _, page := range site.PagesByKind(KindSection) {
+		page := page
+		wp.Submit(func() {
+			errs.add(s.renderSection(page, t, templates))
+		})
+	}
+	wp.StopWait()
+	s.logger.Info("Finished section rendering")
+	return errs.err()
+}
+
+func (s SectionPlugin) renderSection(page *Page, t AssisTemplate, templates Templates) error {
+	for _, output := range page.Outputs() {
+		templateFile, err := templates.Lookup(output)
+		if err != nil {
+			return err
+		}
+
+		if filepath.Ext(output.Source) == HTML {
+			if err := t.RenderPage(templates, templateFile, output); err != nil {
+				return err
+			}
+		} else if templateFile == "" {
+			return fmt.Errorf("%s: no list template, add a layout header or a %s template",
+				output.Source, formatName(ListTemplate, output.Format))
+		} else if err := t.RenderLayout(templates, templateFile, output.Output, output); err != nil {
+			return err
+		}
+
+		s.logger.Info("Rendered section to: " + output.Output)
+	}
+	return nil
+}
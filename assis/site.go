@@ -0,0 +1,285 @@
+package assis
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Taxonomies built from the front matter of every page.
+var taxonomies = []string{"tags", "authors"}
+
+// Taxonomy maps each term to the pages using it.
+type Taxonomy map[string][]*Page
+
+// Section groups the pages of one directory of the content folder. Index is
+// the page built from its _index file, if any.
+type Section struct {
+	Path        string
+	Title       string
+	Description string
+	Weight      int
+	Index       *Page
+	Parent      *Section `json:"-"`
+	Pages       []*Page
+	Sections    []*Section
+}
+
+// Site holds the site-wide values shared by every render and exposed to
+// templates as .Site, together with the graph of every page, section and
+// taxonomy. The graph is built before rendering starts.
+type Site struct {
+	Title      string
+	BaseURL    string
+	Language   string
+	Params     map[string]interface{}
+	Data       map[string]interface{}
+	Pages      []*Page
+	Sections   map[string]*Section
+	Tree       *Section
+	Taxonomies map[string]Taxonomy
+	Menus      Menus
+
+	menus map[string][]MenuItem
+	mu    sync.Mutex
+}
+
+// NewSite makes the site of a build from the config. Params and menus are
+// copied, so what the build adds to them stays out of the config.
+func NewSite(config *Config) *Site {
+	params := map[string]interface{}{}
+	for key, value := range config.Params {
+		params[key] = value
+	}
+	menus := map[string][]MenuItem{}
+	for name, items := range config.Menus {
+		menus[name] = items
+	}
+
+	return &Site{
+		Title:      config.Title,
+		BaseURL:    config.BaseURL,
+		Language:   config.Language,
+		Params:     params,
+		Data:       map[string]interface{}{},
+		Pages:      []*Page{},
+		Sections:   map[string]*Section{},
+		Taxonomies: map[string]Taxonomy{},
+		Menus:      Menus{},
+		menus:      menus,
+	}
+}
+
+// AbsURL joins a permalink to the base URL of the site.
+func (s *Site) AbsURL(permalink string) string {
+	if s.BaseURL == "" {
+		return "/" + strings.TrimPrefix(permalink, "/")
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + strings.TrimPrefix(permalink, "/")
+}
+
+// AddPage puts a page in the graph. Sections and taxonomies are updated by
+// Index once every page is added.
+func (s *Site) AddPage(page *Page) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page.Site = s
+	s.Pages = append(s.Pages, page)
+}
+
+// Index sorts the pages and builds the sections and taxonomies from them.
+// Sections are linked to their subsections, every directory up to the content
+// root having one, and take title, description, weight and page order from
+// their index page, when there is one. Without a sort on the index, pages and
+// subsections are ordered by weight and then title.
+func (s *Site) Index() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.SliceStable(s.Pages, func(i, j int) bool {
+		return s.Pages[i].Permalink < s.Pages[j].Permalink
+	})
+
+	s.Sections = map[string]*Section{}
+	s.Taxonomies = map[string]Taxonomy{}
+	for _, name := range taxonomies {
+		s.Taxonomies[name] = Taxonomy{}
+	}
+
+	for _, page := range s.Pages {
+		section := s.section(page.SectionPath)
+		page.Section = section
+		if page.Kind == KindSection {
+			section.Index = page
+		} else {
+			section.Pages = append(section.Pages, page)
+		}
+
+		for _, name := range taxonomies {
+			for _, term := range listParam(page.Params, name) {
+				s.Taxonomies[name][term] = append(s.Taxonomies[name][term], page)
+			}
+		}
+	}
+
+	var dirs []string
+	for dir := range s.Sections {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		for dir != "/" {
+			parent := s.section(parentSection(dir))
+			s.Sections[dir].Parent = parent
+			if !parent.hasChild(dir) {
+				parent.Sections = append(parent.Sections, s.Sections[dir])
+			}
+			dir = parent.Path
+		}
+	}
+	s.Tree = s.section("/")
+
+	for _, section := range s.Sections {
+		if index := section.Index; index != nil {
+			if index.Title != "" {
+				section.Title = index.Title
+			}
+			section.Description = index.Description
+			section.Weight = index.Weight
+		}
+		if section.Title == "" {
+			section.Title = humanize(sectionTitle(section.Path))
+		}
+	}
+	if s.Tree.Title == "" {
+		s.Tree.Title = s.Title
+	}
+
+	for _, section := range s.Sections {
+		sort.SliceStable(section.Sections, func(i, j int) bool {
+			a, b := section.Sections[i], section.Sections[j]
+			return byWeight(a.Weight, b.Weight, a.Title, b.Title)
+		})
+
+		if index := section.Index; index != nil && stringParam(index.Params, "sort") != "" {
+			section.Pages = sortPages(section.Pages, stringParam(index.Params, "sort"), stringParam(index.Params, "order"))
+		} else {
+			sort.SliceStable(section.Pages, func(i, j int) bool {
+				a, b := section.Pages[i], section.Pages[j]
+				return byWeight(a.Weight, b.Weight, a.Title, b.Title)
+			})
+		}
+
+		if index := section.Index; index != nil {
+			index.Pages = section.Pages
+			index.Sections = section.Sections
+		}
+	}
+}
+
+// section returns the section of a path, creating it when needed.
+func (s *Site) section(dir string) *Section {
+	if section, ok := s.Sections[dir]; ok {
+		return section
+	}
+	section := &Section{Path: dir, Pages: []*Page{}, Sections: []*Section{}}
+	s.Sections[dir] = section
+	return section
+}
+
+func (s *Section) hasChild(dir string) bool {
+	for _, child := range s.Sections {
+		if child.Path == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func parentSection(dir string) string {
+	return sectionPath(path.Dir(dir))
+}
+
+// sectionTitle is the title of sections without an index page: the name of
+// their directory.
+func sectionTitle(dir string) string {
+	if dir == "/" {
+		return ""
+	}
+	return path.Base(dir)
+}
+
+// sortPages orders pages by a field such as "date", "title", "weight" or any
+// field path the collection functions accept. No field keeps the order.
+func sortPages(pages []*Page, by, order string) []*Page {
+	if by == "" {
+		return pages
+	}
+	if alias, ok := sortAliases[strings.ToLower(by)]; ok {
+		by = alias
+	}
+
+	sorted, err := CollectionPlugin{}.sortBy(by, strings.ToLower(defaultString(order, "asc")), pages)
+	if err != nil {
+		return pages
+	}
+	return sorted.([]*Page)
+}
+
+var sortAliases = map[string]string{
+	"date":      "Date",
+	"title":     "Title",
+	"weight":    "Params.weight",
+	"permalink": "Permalink",
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// checkOutputs makes sure no two pages write the same file, as an index.html
+// next to an _index file would.
+func (s *Site) checkOutputs() error {
+	outputs := map[string]*Page{}
+	for _, page := range s.Pages {
+		for _, output := range page.Outputs() {
+			if other, ok := outputs[output.Output]; ok {
+				return fmt.Errorf("%s and %s are both rendered to %s", other.Source, output.Source, output.Output)
+			}
+			outputs[output.Output] = output
+		}
+	}
+	return nil
+}
+
+// PagesByKind returns the pages of one kind, such as KindArticle.
+func (s *Site) PagesByKind(kind string) []*Page {
+	out := []*Page{}
+	for _, page := range s.Pages {
+		if page.Kind == kind {
+			out = append(out, page)
+		}
+	}
+	return out
+}
+
+// GetPage finds a page by its permalink or its source file.
+func (s *Site) GetPage(ref string) *Page {
+	ref = strings.TrimPrefix(ref, "/")
+	for _, page := range s.Pages {
+		if page.Permalink == ref || strings.TrimPrefix(page.Source, "/") == ref {
+			return page
+		}
+	}
+	return nil
+}
+
+// GetSection returns the section of a directory, e.g. "/articles".
+func (s *Site) GetSection(dir string) *Section {
+	return s.Sections[sectionPath(dir)]
+}
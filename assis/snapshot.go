@@ -0,0 +1,105 @@
+package assis
+
+import (
+	"sort"
+	"strings"
+)
+
+// Snapshot holds the article collections of the render phase: the articles
+// of every section, collected once every page is parsed and before any is
+// rendered. The collections aren't changed afterwards, so every template,
+// whatever plugin or goroutine renders it, sees the same complete ones. Site
+// and the pages the articles point to are the live ones of the build, shared
+// with render plugins, which the snapshot doesn't keep from changing them.
+type Snapshot struct {
+	Site     *Site
+	articles map[string][]Article
+	sources  map[string]Article
+	sections []string
+}
+
+// PluginSnapshotFunction registers template functions reading from the
+// snapshot of the build, like the article collections.
+type PluginSnapshotFunction interface {
+	OnRegisterSnapshotFunction(*Snapshot) map[string]interface{}
+}
+
+// NewSnapshot collects the articles of a site, in the order of their
+// sections.
+func NewSnapshot(site *Site) *Snapshot {
+	s := &Snapshot{Site: site, articles: map[string][]Article{}, sources: map[string]Article{}}
+	if site == nil {
+		return s
+	}
+
+	for dir := range site.Sections {
+		s.sections = append(s.sections, dir)
+	}
+	sort.Strings(s.sections)
+	for _, dir := range s.sections {
+		for _, page := range site.Sections[dir].Pages {
+			if page.Kind != KindArticle {
+				continue
+			}
+			article := newArticle(page)
+			s.articles[page.SectionPath] = append(s.articles[page.SectionPath], article)
+			s.sources[page.Source] = article
+		}
+	}
+	return s
+}
+
+// article returns the article of a page, collected or made on the spot.
+func (s *Snapshot) article(page *Page) Article {
+	if s != nil {
+		if article, ok := s.sources[page.Source]; ok {
+			return article
+		}
+	}
+	return newArticle(page)
+}
+
+// entries returns the sections with articles matching path, in order. With
+// recursive set, sections below path are included too.
+func (s *Snapshot) entries(path string, recursive bool) []string {
+	root := sectionPath(path)
+
+	var entries []string
+	for _, entry := range s.sections {
+		if _, ok := s.articles[entry]; !ok {
+			continue
+		}
+		if entry == root || (recursive && (root == "/" || strings.HasPrefix(entry, root+"/"))) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// collection returns a new list of the published articles of path, pinned or
+// not.
+func (s *Snapshot) collection(path string, pin, recursive bool) []Article {
+	out := []Article{}
+	for _, entry := range s.entries(path, recursive) {
+		for _, article := range s.articles[entry] {
+			if article.Pin == pin && article.Published {
+				out = append(out, article)
+			}
+		}
+	}
+	return out
+}
+
+// allArticles returns a new list of every published article of the site,
+// pinned or not.
+func (s *Snapshot) allArticles() []Article {
+	out := []Article{}
+	for _, entry := range s.entries("/", true) {
+		for _, article := range s.articles[entry] {
+			if article.Published {
+				out = append(out, article)
+			}
+		}
+	}
+	return out
+}
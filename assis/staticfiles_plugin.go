@@ -5,7 +5,9 @@ import (
 	"github.com/gammazero/workerpool"
 	"go.uber.org/zap"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 )
 
 type StaticFilesPlugin struct {
@@ -22,17 +24,20 @@ func NewStaticFilesPlugin(config *Config, allowedExt []string, logger *zap.Logge
 	}
 }
 
-func (s StaticFilesPlugin) AfterLoadFiles(files SiteFiles) error {
+func (s StaticFilesPlugin) Describe() PluginInfo {
+	return PluginInfo{Name: "static_files", Version: builtinVersion}
+}
+
+func (s StaticFilesPlugin) AfterLoadFiles(site *Site, files SiteFiles) error {
 	s.logger.Info("Start static files copy")
 
+	var errs errorCollector
 	wp := workerpool.New(2)
 	maxJobs := 0
 	for _, container := range files {
 		container := container
 		wp.Submit(func() {
-			if err := s.copyStaticFile(container); err != nil {
-				s.logger.Error(err.Error())
-			}
+			errs.add(s.copyStaticFile(container))
 		})
 		maxJobs += 1
 		if maxJobs == 4 {
@@ -42,41 +47,82 @@ func (s StaticFilesPlugin) AfterLoadFiles(files SiteFiles) error {
 		}
 	}
 	wp.StopWait()
+	errs.add(s.copyThemeFiles(files))
 	s.logger.Info("Finished static files copy")
-	return nil
+	return errs.err()
 }
 
 func (s StaticFilesPlugin) copyStaticFile(container *FileContainer) error {
-	files := container.FilterExt(s.allowedExt)
-	for _, file := range files {
-		err := func() error {
-			if err := GenerateDir(container.OutputFilename(file)); err != nil {
-				return err
-			}
+	for _, file := range container.FilterExt(s.allowedExt) {
+		if err := s.copyFile(container.FullFilename(file), container.OutputFilename(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			source, err := os.Open(container.FullFilename(file))
-			defer source.Close()
-			if err != nil {
+// copyThemeFiles copies the static folder of every theme to the output
+// folder. A file the site, or a theme listed before, already has at the same
+// path is skipped.
+func (s StaticFilesPlugin) copyThemeFiles(files SiteFiles) error {
+	copied := map[string]bool{}
+	for _, container := range files {
+		for _, file := range container.FilterExt(s.allowedExt) {
+			copied[filepath.ToSlash(container.OutputFilename(file))] = true
+		}
+	}
+
+	for _, root := range s.config.themeRoots() {
+		static := filepath.Join(root, ThemeStatic)
+		if exists, _ := Exists(static); !exists {
+			continue
+		}
+
+		err := filepath.WalkDir(static, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
 				return err
 			}
-
-			target, err := os.Create(container.OutputFilename(file))
-			defer target.Close()
+			rel, err := filepath.Rel(static, path)
 			if err != nil {
 				return err
 			}
 
-			if _, err = io.Copy(target, source); err != nil {
-				return err
+			target := filepath.ToSlash(filepath.Join(s.config.Output, rel))
+			if copied[target] {
+				return nil
 			}
-
-			s.logger.Info(fmt.Sprintf("Source file: %s", container.FullFilename(file)))
-			s.logger.Info(fmt.Sprintf("Target file: %s", container.OutputFilename(file)))
-			return nil
-		}()
+			copied[target] = true
+			return s.copyFile(path, target)
+		})
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func (s StaticFilesPlugin) copyFile(sourceFile, targetFile string) error {
+	if err := GenerateDir(targetFile); err != nil {
+		return err
+	}
+
+	source, err := os.Open(sourceFile)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.Create(targetFile)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	if _, err = io.Copy(target, source); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("Source file: %s", sourceFile))
+	s.logger.Info(fmt.Sprintf("Target file: %s", targetFile))
+	return nil
+}
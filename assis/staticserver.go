@@ -2,6 +2,7 @@ package assis
 
 import (
 	"context"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,11 +17,14 @@ import (
 type StaticServe struct {
 	logger  *zap.Logger
 	watcher *fsnotify.Watcher
-	listen  func() error
+	listen  func(changed string) error
 	config  *Config
 }
 
-func NewStaticServer(config *Config, logger *zap.Logger, listen func() error) StaticServe {
+// NewStaticServer serves the output folder. With listen set, changes to the
+// content, template, partials or data folders, or to the folder of a theme,
+// call it with the changed file.
+func NewStaticServer(config *Config, logger *zap.Logger, listen func(changed string) error) StaticServe {
 	return StaticServe{
 		logger: logger,
 		config: config,
@@ -38,6 +42,16 @@ func (s StaticServe) ListenAndServe() error {
 
 	abs, _ := filepath.Abs(s.config.Output)
 
+	// Serve the files of the output formats Go doesn't know with their media
+	// type, e.g. .webmanifest.
+	for _, format := range s.config.AllOutputFormats() {
+		if mime.TypeByExtension(format.Extension) == "" {
+			if err := mime.AddExtensionType(format.Extension, format.MediaType); err != nil {
+				s.logger.Warn(err.Error())
+			}
+		}
+	}
+
 	http.Handle("/", loggingHandler(http.FileServer(http.Dir(abs))))
 
 	done := make(chan os.Signal, 1)
@@ -82,13 +96,19 @@ func (s StaticServe) Watch(os OS) error {
 	s.watcher, _ = fsnotify.NewWatcher()
 	defer s.watcher.Close()
 
-	abs, err := filepath.Abs(s.config.Content)
-	if err != nil {
-		return err
-	}
+	dirs := []string{s.config.Content, s.config.Template.Path, s.config.Template.Partials, s.config.Data}
+	for _, dir := range append(dirs, s.config.themeRoots()...) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
 
-	if err := filepath.Walk(abs, s.watchDir); err != nil {
-		return err
+		if exists, _ := Exists(abs); !exists {
+			continue
+		}
+		if err := filepath.Walk(abs, s.watchDir); err != nil {
+			return err
+		}
 	}
 
 	done := make(chan bool)
@@ -99,7 +119,7 @@ func (s StaticServe) Watch(os OS) error {
 			case event := <-s.watcher.Events:
 				if os.ShouldGenerate(event.Op.String()) {
 					s.logger.Info("File update")
-					if err := s.listen(); err != nil {
+					if err := s.listen(event.Name); err != nil {
 						s.logger.Info(err.Error())
 					}
 				}
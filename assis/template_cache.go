@@ -0,0 +1,157 @@
+package assis
+
+import (
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template/parse"
+)
+
+// TemplateCache keeps parsed templates between renders: the base set, made of
+// the layout and the partials, and the template of each file pages are
+// rendered with. Pages get a clone of the base set with copies of their
+// template trees added, so executing a page never changes what is cached.
+// The cache outlives a build; in watch mode Invalidate drops what a changed
+// file affects. It also keeps the templates each file defines, for Explain.
+type TemplateCache struct {
+	mu        sync.Mutex
+	base      *template.Template
+	baseFiles []string
+	files     map[string]*template.Template
+	defines   map[string][]string
+}
+
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{files: map[string]*template.Template{}, defines: map[string][]string{}}
+}
+
+// Invalidate forgets the templates parsed from file. A change to the layout
+// or to a partial drops the whole base set.
+func (c *TemplateCache) Invalidate(file string) {
+	file = absPath(file)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, file)
+	delete(c.defines, file)
+	for _, baseFile := range c.baseFiles {
+		if baseFile == file {
+			c.base = nil
+			c.baseFiles = nil
+			return
+		}
+	}
+}
+
+// clone returns a copy of the base set parsed from files, parsing it first
+// when the cache has none or the layout and partials changed.
+func (c *TemplateCache) clone(files []string, newTemplate func() *template.Template) (*template.Template, error) {
+	abs := make([]string, len(files))
+	for i, file := range files {
+		abs[i] = absPath(file)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.base == nil || !sameFiles(abs, c.baseFiles) {
+		base := newTemplate()
+		for i, file := range abs {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := base.New(templateName(files[i])).Parse(string(b)); err != nil {
+				return nil, err
+			}
+			c.defines[file] = definesOf(base, templateName(files[i]))
+		}
+		c.base, c.baseFiles = base, abs
+	}
+	return c.base.Clone()
+}
+
+// file returns the templates parsed from one template file.
+func (c *TemplateCache) file(file string, newTemplate func() *template.Template) (*template.Template, error) {
+	name := templateName(file)
+	file = absPath(file)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.files[file]; ok {
+		return cached, nil
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := newTemplate().New(name).Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	c.files[file] = parsed
+	c.defines[file] = definesOf(parsed, name)
+	return parsed, nil
+}
+
+// definesOf returns the templates defined by each file, as parsed last.
+func (c *TemplateCache) definesOf(files []string) map[string][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defines := map[string][]string{}
+	for _, file := range files {
+		if names, ok := c.defines[absPath(file)]; ok {
+			defines[file] = names
+		}
+	}
+	return defines
+}
+
+func sameFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// templateName is the name a file is parsed under: its slash path, so files
+// sharing a base name in different folders stay apart and template errors
+// point at the right one.
+func templateName(file string) string {
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// definesOf returns, sorted, the templates of set the file parsed under name
+// defines, told apart by the name they were parsed under.
+func definesOf(set *template.Template, name string) []string {
+	var trees []*parse.Tree
+	for _, tpl := range set.Templates() {
+		trees = append(trees, tpl.Tree)
+	}
+	return defined(trees, name)
+}
+
+func defined(trees []*parse.Tree, name string) []string {
+	var defines []string
+	for _, tree := range trees {
+		if tree != nil && tree.ParseName == name && tree.Name != name {
+			defines = append(defines, tree.Name)
+		}
+	}
+	sort.Strings(defines)
+	return defines
+}
+
+func absPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return filepath.ToSlash(abs)
+	}
+	return filepath.ToSlash(file)
+}
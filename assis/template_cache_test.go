@@ -0,0 +1,64 @@
+package assis
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"testing"
+)
+
+func TestTemplateCache(t *testing.T) {
+	newTemplate := NewAssisTemplate(template.FuncMap{"site": func() *Site { return nil }}).GetTemplate
+	base := []string{"./mock/_site/template/layout.html"}
+	single := "./mock/_site/template/articles/single.html"
+
+	t.Run("base set is parsed once and cloned", func(t *testing.T) {
+		cache := NewTemplateCache()
+		first, err := cache.clone(base, newTemplate)
+		assert.NoError(t, err)
+		parsed := cache.base
+
+		second, err := cache.clone(base, newTemplate)
+		assert.NoError(t, err)
+		assert.Same(t, parsed, cache.base)
+		assert.NotSame(t, first, second)
+	})
+
+	t.Run("page templates are cached per file", func(t *testing.T) {
+		cache := NewTemplateCache()
+		first, err := cache.file(single, newTemplate)
+		assert.NoError(t, err)
+		second, err := cache.file(single, newTemplate)
+		assert.NoError(t, err)
+		assert.Same(t, first, second)
+	})
+
+	t.Run("invalidate a changed file", func(t *testing.T) {
+		cache := NewTemplateCache()
+		_, _ = cache.clone(base, newTemplate)
+		page, _ := cache.file(single, newTemplate)
+
+		cache.Invalidate(single)
+		assert.NotNil(t, cache.base)
+		reparsed, _ := cache.file(single, newTemplate)
+		assert.NotSame(t, page, reparsed)
+
+		cache.Invalidate(base[0])
+		assert.Nil(t, cache.base)
+	})
+
+	t.Run("rendering leaves the cache untouched", func(t *testing.T) {
+		templates := Templates{baseOrdered: base, cache: NewTemplateCache()}
+		a := NewAssisTemplate(template.FuncMap{"site": func() *Site { return &Site{Title: "Cached"} }})
+		page := &Page{Title: "<Title>", Site: &Site{}}
+
+		for i := 0; i < 2; i++ {
+			target, err := a.parse(templates, single)
+			assert.NoError(t, err)
+
+			var out bytes.Buffer
+			assert.NoError(t, target.ExecuteTemplate(&out, "layout", page))
+			assert.Contains(t, out.String(), "&lt;Title&gt; - Cached")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package assis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ThemeConfigFile holds the config defaults of a theme, at its root.
+const ThemeConfigFile = "theme.json"
+
+// ThemeStatic is the folder of a theme whose files are copied as they are to
+// the output folder.
+const ThemeStatic = "static"
+
+// Themes lists the themes of a site, the first one taking precedence over the
+// next. The config accepts a single name as well as a list.
+type Themes []string
+
+func (t *Themes) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = Themes{}
+		if name != "" {
+			*t = Themes{name}
+		}
+		return nil
+	}
+
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return fmt.Errorf("theme must be a name or a list of names")
+	}
+	*t = names
+	return nil
+}
+
+// templateLayer is one folder templates are read from: the site or a theme.
+type templateLayer struct {
+	Path     string
+	Partials string
+}
+
+// themeRoots returns the folder of each theme, in the order of the config.
+func (c *Config) themeRoots() []string {
+	var roots []string
+	for _, name := range c.Theme {
+		roots = append(roots, filepath.ToSlash(filepath.Join(c.ThemesDir, name)))
+	}
+	return roots
+}
+
+// templateLayers lists the template folders, the one of the site first, then
+// the one of each theme. Themes are laid out as a site: their templates and
+// partials are found at the same paths, relative to their root, as the ones
+// of the site are relative to the site root.
+func (c *Config) templateLayers() []templateLayer {
+	layers := []templateLayer{{Path: filepath.ToSlash(c.Template.Path), Partials: filepath.ToSlash(c.Template.Partials)}}
+	templates, partials := siteRelative(c.SiteRoot, c.Template.Path, "template"), siteRelative(c.SiteRoot, c.Template.Partials, "partials")
+	for _, root := range c.themeRoots() {
+		layers = append(layers, templateLayer{
+			Path:     filepath.ToSlash(filepath.Join(root, templates)),
+			Partials: filepath.ToSlash(filepath.Join(root, partials)),
+		})
+	}
+	return layers
+}
+
+func siteRelative(root, path, fallback string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+		return fallback
+	}
+	return rel
+}
+
+// applyThemes fills the settings the site leaves unset from the theme.json of
+// its themes: title, language, base URL, each param and each menu. They are
+// merged into the site of the build, the config is left as loaded, so serve
+// picks up changes to theme.json on each rebuild.
+func (c *Config) applyThemes(site *Site) error {
+	for _, root := range c.themeRoots() {
+		if exists, _ := Exists(root); !exists {
+			return fmt.Errorf("theme %s not found in %s", filepath.Base(root), c.ThemesDir)
+		}
+
+		b, err := os.ReadFile(filepath.Join(root, ThemeConfigFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var defaults Config
+		if err := json.Unmarshal(b, &defaults); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(root, ThemeConfigFile), err)
+		}
+
+		if site.Title == "" {
+			site.Title = defaults.Title
+		}
+		if site.Language == "" {
+			site.Language = defaults.Language
+		}
+		if site.BaseURL == "" {
+			site.BaseURL = defaults.BaseURL
+		}
+		for key, value := range defaults.Params {
+			if _, ok := site.Params[key]; !ok {
+				site.Params[key] = value
+			}
+		}
+		for name, items := range defaults.Menus {
+			if _, ok := site.menus[name]; !ok {
+				site.menus[name] = items
+			}
+		}
+	}
+	return nil
+}
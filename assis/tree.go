@@ -0,0 +1,129 @@
+package assis
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Node is one entry of the section tree, a page or a subsection, as listed by
+// Children for sidebars.
+type Node struct {
+	Title     string
+	Permalink string
+	Weight    int
+	Page      *Page
+	Section   *Section
+}
+
+func (n Node) IsSection() bool {
+	return n.Section != nil
+}
+
+func (s Section) String() string {
+	return s.Path
+}
+
+// Permalink of the section: its _index page or, failing that, the index.html
+// page of its directory. Empty when the section has neither.
+func (s *Section) Permalink() string {
+	if s.Index != nil {
+		return s.Index.Permalink
+	}
+	for _, page := range s.Pages {
+		if page.Kind == KindPage && page.ID == "index" {
+			return page.Permalink
+		}
+	}
+	return ""
+}
+
+// Ancestors lists the sections above this one, starting at the root.
+func (s *Section) Ancestors() []*Section {
+	var out []*Section
+	for parent := s.Parent; parent != nil; parent = parent.Parent {
+		out = append([]*Section{parent}, out...)
+	}
+	return out
+}
+
+// Children lists the subsections and pages of the section, ordered by weight
+// and then title. Pages without a weight come after the weighted ones.
+func (s *Section) Children() []Node {
+	var out []Node
+	for _, section := range s.Sections {
+		out = append(out, Node{Title: section.Title, Permalink: section.Permalink(), Weight: section.Weight, Section: section})
+	}
+	for _, page := range s.Pages {
+		out = append(out, Node{Title: page.Title, Permalink: page.Permalink, Weight: page.Weight, Page: page})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return byWeight(out[i].Weight, out[j].Weight, out[i].Title, out[j].Title)
+	})
+	return out
+}
+
+// Contains tells whether a page is in this section or below it, to mark the
+// open branches of a sidebar.
+func (s *Section) Contains(page *Page) bool {
+	if page == nil {
+		return false
+	}
+	return s.Path == "/" || page.SectionPath == s.Path || strings.HasPrefix(page.SectionPath, s.Path+"/")
+}
+
+// Parent is the section holding the page. For section pages it is the parent
+// of the section they describe.
+func (p *Page) Parent() *Section {
+	if p.Section == nil {
+		return nil
+	}
+	if p.Kind == KindSection {
+		return p.Section.Parent
+	}
+	return p.Section
+}
+
+// Ancestors lists the sections above the page, starting at the root, for
+// breadcrumbs.
+func (p *Page) Ancestors() []*Section {
+	parent := p.Parent()
+	if parent == nil {
+		return []*Section{}
+	}
+	return append(parent.Ancestors(), parent)
+}
+
+// Children lists the subsections and pages of a section page. Other pages
+// have none.
+func (p *Page) Children() []Node {
+	if p.Kind != KindSection || p.Section == nil {
+		return []Node{}
+	}
+	return p.Section.Children()
+}
+
+func byWeight(a, b int, titleA, titleB string) bool {
+	if a != b {
+		switch {
+		case a == 0:
+			return false
+		case b == 0:
+			return true
+		}
+		return a < b
+	}
+	return titleA < titleB
+}
+
+// humanize turns a file or directory name into a title: "getting-started"
+// becomes "Getting started".
+func humanize(name string) string {
+	name = strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+	if name == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:]
+}
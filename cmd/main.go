@@ -1,26 +1,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/luizfsnunes/assis/assis"
 	"go.uber.org/zap"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 )
 
 func main() {
 	if len(os.Args) <= 1 {
-		fmt.Println("no command supplied. expected: generate, serve")
+		fmt.Println("no command supplied. expected: generate, serve, render")
 		os.Exit(1)
 	}
 
 	serve := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveCfg := serve.String("config", "", "Config file")
 	watch := serve.Bool("watch", false, "Watch files and hot-reload")
+	serveStrict := serve.Bool("strict", false, "Fail on templates reading missing keys")
 
 	generate := flag.NewFlagSet("generate", flag.ExitOnError)
 	generateCfg := generate.String("config", "", "Config file")
+	generateStrict := generate.Bool("strict", false, "Fail on templates reading missing keys")
+
+	render := flag.NewFlagSet("render", flag.ExitOnError)
+	renderCfg := render.String("config", "", "Config file")
+	renderStrict := render.Bool("strict", false, "Fail on templates reading missing keys")
+	explain := render.Bool("explain", false, "Explain how the templates of the page were resolved")
 
 	logger := buildZap()
 
@@ -36,11 +46,14 @@ func main() {
 			logger.Error(err.Error())
 			os.Exit(1)
 		}
+		config.Strict = config.Strict || *serveStrict
 
-		var fn func() error
+		var fn func(changed string) error
 		if *watch == true {
-			fn = func() error {
-				return generateSite(config, logger)
+			cache := assis.NewTemplateCache()
+			fn = func(changed string) error {
+				cache.Invalidate(changed)
+				return generateSite(context.Background(), config, cache, changed, logger)
 			}
 		}
 		server := assis.NewStaticServer(config, logger, fn)
@@ -59,8 +72,40 @@ func main() {
 			logger.Error(err.Error())
 			os.Exit(1)
 		}
+		config.Strict = config.Strict || *generateStrict
 
-		if err = generateSite(config, logger); err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err = generateSite(ctx, config, assis.NewTemplateCache(), "", logger); err != nil {
+			fmt.Print(err.Error())
+			os.Exit(1)
+		}
+	case "render":
+		// Flags are accepted before and after the content file.
+		if err := render.Parse(os.Args[2:]); err != nil {
+			fmt.Print(err.Error())
+			os.Exit(1)
+		}
+		source := render.Arg(0)
+		if render.NArg() > 1 {
+			if err := render.Parse(render.Args()[1:]); err != nil {
+				fmt.Print(err.Error())
+				os.Exit(1)
+			}
+		}
+		if source == "" {
+			fmt.Println("no content file supplied. expected: render <content file> [--explain]")
+			os.Exit(1)
+		}
+
+		config, err := assis.NewConfigFromFile(*renderCfg)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		config.Strict = config.Strict || *renderStrict
+
+		if err = renderPage(config, source, *explain, zap.NewNop()); err != nil {
 			fmt.Print(err.Error())
 			os.Exit(1)
 		}
@@ -81,15 +126,37 @@ func buildZap() *zap.Logger {
 	return logger
 }
 
-func generateSite(config *assis.Config, logger *zap.Logger) error {
-	plugins := []interface{}{
-		assis.NewArticlePlugin(config, logger),
-		assis.NewHTMLPlugin(config, logger),
-		assis.NewStaticFilesPlugin(config, []string{".svg", ".js", ".png", ".jpg", ".jpeg", ".gif", ".css"}, logger),
-		assis.NewMinifyPlugin(logger),
+// newAssis returns the generator of a build with the plugins the config sets
+// up, and a func closing them once the build is done.
+func newAssis(config *assis.Config, logger *zap.Logger) (assis.Assis, func(), error) {
+	pipeline, err := assis.NewPipeline(config, logger)
+	if err != nil {
+		return assis.Assis{}, nil, err
+	}
+	closePipeline := func() {
+		if err := pipeline.Close(); err != nil {
+			logger.Error(err.Error())
+		}
 	}
 
-	assisGenerator := assis.NewAssis(config, plugins, logger)
+	return assis.NewAssis(config, pipeline.Plugins, logger), closePipeline, nil
+}
+
+// generateSite builds the site until ctx is cancelled. In watch mode changed is
+// the file whose change triggered the build.
+func generateSite(ctx context.Context, config *assis.Config, cache *assis.TemplateCache, changed string, logger *zap.Logger) error {
+	assisGenerator, closePipeline, err := newAssis(config, logger)
+	if err != nil {
+		return err
+	}
+	defer closePipeline()
+	assisGenerator.UseTemplateCache(cache)
+	assisGenerator.WithContext(ctx)
+	if changed != "" {
+		if err := assisGenerator.WatchEvent(changed); err != nil {
+			return err
+		}
+	}
 	if err := assisGenerator.LoadFilesAsync(); err != nil {
 		return err
 	}
@@ -98,3 +165,52 @@ func generateSite(config *assis.Config, logger *zap.Logger) error {
 	}
 	return nil
 }
+
+// renderPage renders one content file in a temporary folder, from a snapshot
+// of the whole site so the page sees the same collections and menus as in a
+// full build, and prints its output, after how it was rendered when explain is
+// set.
+func renderPage(config *assis.Config, source string, explain bool, logger *zap.Logger) error {
+	output, err := ioutil.TempDir("", "assis-render")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(output)
+	config.Output = output
+
+	assisGenerator, closePipeline, err := newAssis(config, logger)
+	if err != nil {
+		return err
+	}
+	defer closePipeline()
+	if err := assisGenerator.LoadFilesAsync(); err != nil {
+		return err
+	}
+	page, err := assisGenerator.Page(source)
+	if err != nil {
+		return err
+	}
+
+	renderErr := assisGenerator.RenderPage(page)
+	if explain {
+		explanation, err := assisGenerator.Explain(page)
+		if err != nil {
+			if renderErr != nil {
+				return renderErr
+			}
+			return err
+		}
+		fmt.Println(explanation.String())
+		fmt.Println("output:")
+	}
+	if renderErr != nil {
+		return renderErr
+	}
+
+	b, err := ioutil.ReadFile(page.Output)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
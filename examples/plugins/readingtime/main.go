@@ -0,0 +1,69 @@
+// Command readingtime is an example external plugin. It adds the reading time
+// of each HTML page to its head, as <meta name="reading-time">, and gives
+// templates the words per minute it assumes in
+// .Site.Data.readingtime.words_per_minute. Build it and add it to the config:
+//
+//	"external_plugins": [
+//	  {"command": "./plugins/readingtime", "options": {"words_per_minute": 250}}
+//	]
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/luizfsnunes/assis/sdk"
+)
+
+var (
+	tags   = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]*>`)
+	header = regexp.MustCompile(`(?s)^.*?<body[^>]*>`)
+)
+
+func main() {
+	wordsPerMinute := 200
+	total := 0
+
+	var plugin *sdk.Plugin
+	plugin = &sdk.Plugin{
+		Name:    "readingtime",
+		Version: "1.0.0",
+		Initialize: func(params sdk.InitializeParams) error {
+			if wpm, ok := params.Options["words_per_minute"].(float64); ok {
+				if wpm <= 0 {
+					return fmt.Errorf("words_per_minute must be positive")
+				}
+				wordsPerMinute = int(wpm)
+			}
+			return nil
+		},
+		LoadFiles: func(files []string) error {
+			plugin.Logf("debug", "%d content files", len(files))
+			return nil
+		},
+		Data: func() (map[string]interface{}, error) {
+			return map[string]interface{}{"words_per_minute": wordsPerMinute}, nil
+		},
+		TransformPage: func(page sdk.Page) (string, error) {
+			if page.Format != "html" || !strings.Contains(page.Content, "</head>") {
+				return page.Content, nil
+			}
+			words := len(strings.Fields(tags.ReplaceAllString(header.ReplaceAllString(page.Content, ""), " ")))
+			total += words
+			minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+			meta := fmt.Sprintf("<meta name=\"reading-time\" content=\"%d min\">", minutes)
+			return strings.Replace(page.Content, "</head>", meta+"</head>", 1), nil
+		},
+		AfterGenerate: func(files []string) error {
+			plugin.Logf("info", "%d words in %d files", total, len(files))
+			return nil
+		},
+	}
+
+	if err := plugin.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
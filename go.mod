@@ -13,4 +13,5 @@ require (
 	github.com/tdewolff/parse v2.3.4+incompatible // indirect
 	github.com/tdewolff/test v1.0.6 // indirect
 	go.uber.org/zap v1.17.0
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
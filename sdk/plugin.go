@@ -0,0 +1,184 @@
+package sdk
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Plugin is a plugin served over the protocol. The hooks it sets are the ones
+// it tells assis it implements.
+type Plugin struct {
+	Name    string
+	Version string
+
+	// Initialize gets the site and the options of the plugin in the config.
+	Initialize    func(params InitializeParams) error
+	LoadFiles     func(files []string) error
+	Data          func() (map[string]interface{}, error)
+	TransformPage func(page Page) (string, error)
+	AfterGenerate func(files []string) error
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// Serve answers the requests of assis on the standard input and output until
+// it asks the plugin to shut down.
+func (p *Plugin) Serve() error {
+	return p.ServeIO(os.Stdin, os.Stdout)
+}
+
+// ServeIO answers the requests read from r on w.
+func (p *Plugin) ServeIO(r io.Reader, w io.Writer) error {
+	p.mu.Lock()
+	p.out = w
+	p.mu.Unlock()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var req Message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			if err := p.write(nullIDResponse{JSONRPC: "2.0", Error: &Error{Code: CodeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.ID == nil {
+			continue
+		}
+
+		result, err := p.handle(req)
+		res := Message{ID: req.ID}
+		if err != nil {
+			rpcErr, ok := err.(*Error)
+			if !ok {
+				rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+			res.Error = rpcErr
+		} else if res.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err := p.send(res); err != nil {
+			return err
+		}
+		if req.Method == MethodShutdown {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *Plugin) handle(req Message) (interface{}, error) {
+	switch req.Method {
+	case MethodInitialize:
+		var params InitializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if p.Initialize != nil {
+			if err := p.Initialize(params); err != nil {
+				return nil, err
+			}
+		}
+		return InitializeResult{Name: p.Name, Version: p.Version, ProtocolVersion: ProtocolVersion, Hooks: p.hooks()}, nil
+
+	case MethodLoadFiles:
+		var params LoadFilesParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if p.LoadFiles != nil {
+			return struct{}{}, p.LoadFiles(params.Files)
+		}
+
+	case MethodData:
+		if p.Data != nil {
+			data, err := p.Data()
+			return DataResult{Data: data}, err
+		}
+
+	case MethodTransformPage:
+		var params TransformPageParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if p.TransformPage != nil {
+			content, err := p.TransformPage(params.Page)
+			return TransformPageResult{Content: content}, err
+		}
+
+	case MethodAfterGenerate:
+		var params AfterGenerateParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if p.AfterGenerate != nil {
+			return struct{}{}, p.AfterGenerate(params.Files)
+		}
+
+	case MethodShutdown:
+		return struct{}{}, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not implemented", req.Method)}
+}
+
+// hooks lists the methods of the hooks the plugin sets.
+func (p *Plugin) hooks() []string {
+	hooks := []string{}
+	if p.LoadFiles != nil {
+		hooks = append(hooks, MethodLoadFiles)
+	}
+	if p.Data != nil {
+		hooks = append(hooks, MethodData)
+	}
+	if p.TransformPage != nil {
+		hooks = append(hooks, MethodTransformPage)
+	}
+	if p.AfterGenerate != nil {
+		hooks = append(hooks, MethodAfterGenerate)
+	}
+	return hooks
+}
+
+// Logf sends a message to the log of assis, at level debug, info, warn or
+// error. Before Serve, messages go to the standard error.
+func (p *Plugin) Logf(level, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	params, _ := json.Marshal(LogParams{Level: level, Message: msg})
+	if err := p.send(Message{Method: MethodLog, Params: params}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", level, msg)
+	}
+}
+
+// nullIDResponse is the error response to a request whose id couldn't be
+// read, which JSON-RPC sends with a null id.
+type nullIDResponse struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id"`
+	Error   *Error `json:"error"`
+}
+
+func (p *Plugin) send(msg Message) error {
+	msg.JSONRPC = "2.0"
+	return p.write(msg)
+}
+
+func (p *Plugin) write(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.out == nil {
+		return io.ErrClosedPipe
+	}
+	_, err = p.out.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,84 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// serve runs a plugin on the given request lines and returns the lines it
+// answered with.
+func serve(t *testing.T, plugin *Plugin, requests ...string) []string {
+	var out bytes.Buffer
+	assert.NoError(t, plugin.ServeIO(strings.NewReader(strings.Join(requests, "\n")+"\n"), &out))
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func TestPlugin_ServeIO(t *testing.T) {
+	shutdown := `{"jsonrpc":"2.0","id":9,"method":"shutdown"}`
+
+	t.Run("initialize tells the hooks of the plugin", func(t *testing.T) {
+		plugin := &Plugin{Name: "test", Version: "1.0.0", TransformPage: func(page Page) (string, error) { return page.Content, nil }}
+		lines := serve(t, plugin, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocol_version":1}}`, shutdown)
+		assert.Len(t, lines, 2)
+
+		var res Message
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+		assert.Equal(t, int64(1), *res.ID)
+		var result InitializeResult
+		assert.NoError(t, json.Unmarshal(res.Result, &result))
+		assert.Equal(t, InitializeResult{Name: "test", Version: "1.0.0", ProtocolVersion: ProtocolVersion, Hooks: []string{MethodTransformPage}}, result)
+	})
+
+	t.Run("transform pages", func(t *testing.T) {
+		plugin := &Plugin{TransformPage: func(page Page) (string, error) { return strings.ToUpper(page.Content), nil }}
+		lines := serve(t, plugin, `{"jsonrpc":"2.0","id":2,"method":"transform_page","params":{"page":{"content":"hello"}}}`, shutdown)
+
+		var res Message
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+		var result TransformPageResult
+		assert.NoError(t, json.Unmarshal(res.Result, &result))
+		assert.Equal(t, "HELLO", result.Content)
+	})
+
+	t.Run("errors of hooks and unknown methods", func(t *testing.T) {
+		plugin := &Plugin{AfterGenerate: func(files []string) error { return errors.New("no space left") }}
+		lines := serve(t, plugin,
+			`{"jsonrpc":"2.0","id":3,"method":"after_generate","params":{"files":[]}}`,
+			`{"jsonrpc":"2.0","id":4,"method":"data"}`,
+			shutdown)
+		assert.Len(t, lines, 3)
+
+		var res Message
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+		assert.Equal(t, &Error{Code: CodeInternalError, Message: "no space left"}, res.Error)
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &res))
+		assert.Equal(t, CodeMethodNotFound, res.Error.Code)
+	})
+
+	t.Run("parse errors are answered with a null id", func(t *testing.T) {
+		lines := serve(t, &Plugin{}, `not json`, shutdown)
+		assert.Len(t, lines, 2)
+
+		var res map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &res))
+		id, ok := res["id"]
+		assert.True(t, ok)
+		assert.Nil(t, id)
+		assert.Equal(t, float64(CodeParseError), res["error"].(map[string]interface{})["code"])
+	})
+
+	t.Run("notifications get no response", func(t *testing.T) {
+		lines := serve(t, &Plugin{}, `{"jsonrpc":"2.0","method":"initialize"}`, shutdown)
+		assert.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"id":9`)
+	})
+
+	t.Run("stop on shutdown", func(t *testing.T) {
+		lines := serve(t, &Plugin{}, shutdown, `{"jsonrpc":"2.0","id":10,"method":"data"}`)
+		assert.Len(t, lines, 1)
+	})
+}
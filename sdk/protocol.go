@@ -0,0 +1,128 @@
+// Package sdk writes assis plugins that run as programs of their own, in any
+// language, started by assis for each build.
+//
+// Assis and a plugin talk JSON-RPC 2.0 over the standard input and output of
+// the plugin, one JSON message per line. Assis sends requests to the plugin,
+// which answers each one with a response carrying the same id, in order. A
+// plugin can send "log" notifications, without id, at any time; anything it
+// writes to its standard error is logged by assis too. Lines of its output
+// that aren't JSON-RPC messages are logged and otherwise ignored.
+//
+// The first request is "initialize", whose result tells the name of the
+// plugin, its version, the protocol version it speaks, which must be
+// ProtocolVersion, and the hooks it implements. Assis then only sends the
+// requests of those hooks:
+//
+//	load_files      the content files were read: LoadFilesParams, no result
+//	data            the data files were read: DataResult, kept in the site
+//	                data under the plugin name
+//	transform_page  a page was rendered: TransformPageParams, the content
+//	                of the TransformPageResult is written in its place;
+//	                pages are sent one at a time, rendering waits on each
+//	after_generate  the site was generated: AfterGenerateParams, no result
+//
+// Last comes "shutdown", after which the plugin should exit. Errors are
+// returned as JSON-RPC errors and fail the build.
+package sdk
+
+import "encoding/json"
+
+// ProtocolVersion is the version of the protocol described above.
+const ProtocolVersion = 1
+
+// Methods of the protocol. Log is the only one sent by plugins.
+const (
+	MethodInitialize    = "initialize"
+	MethodLoadFiles     = "load_files"
+	MethodData          = "data"
+	MethodTransformPage = "transform_page"
+	MethodAfterGenerate = "after_generate"
+	MethodShutdown      = "shutdown"
+	MethodLog           = "log"
+)
+
+// JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeMethodNotFound = -32601
+	CodeInternalError  = -32603
+)
+
+// Message is any JSON-RPC message: a request or notification has a method,
+// a response a result or an error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Site is what a plugin gets to know about the site it builds.
+type Site struct {
+	Title    string                 `json:"title"`
+	BaseURL  string                 `json:"base_url"`
+	Language string                 `json:"language"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+}
+
+type InitializeParams struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	Site            Site                   `json:"site"`
+	Options         map[string]interface{} `json:"options,omitempty"`
+}
+
+type InitializeResult struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Hooks           []string `json:"hooks"`
+}
+
+type LoadFilesParams struct {
+	Files []string `json:"files"`
+}
+
+type DataResult struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// Page is a rendered output: Content holds what is about to be written to
+// Output. Pages not rendered from a content file only have an output.
+type Page struct {
+	Source    string                 `json:"source,omitempty"`
+	Output    string                 `json:"output"`
+	Permalink string                 `json:"permalink,omitempty"`
+	Kind      string                 `json:"kind,omitempty"`
+	Title     string                 `json:"title,omitempty"`
+	Format    string                 `json:"format,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Content   string                 `json:"content"`
+}
+
+type TransformPageParams struct {
+	Page Page `json:"page"`
+}
+
+type TransformPageResult struct {
+	Content string `json:"content"`
+}
+
+type AfterGenerateParams struct {
+	Files []string `json:"files"`
+}
+
+type LogParams struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}